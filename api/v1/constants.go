@@ -14,4 +14,9 @@ package v1
 
 const (
 	Namespace = "kivebpf-system"
+
+	// Annotation of the v1 KivePolicies converted from v2alpha1, with
+	// the v2alpha1 spec, so that the fields that v1 does not have are
+	// restored when converting back
+	ConversionDataAnnotation = "kivebpf.san7o.github.io/conversion-data"
 )
@@ -13,6 +13,9 @@
 package v1
 
 import (
+	"encoding/json"
+	"fmt"
+
 	v2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
 	"sigs.k8s.io/controller-runtime/pkg/conversion"
 )
@@ -59,6 +62,39 @@ func (src *KivePolicy) ConvertTo(dstRaw conversion.Hub) error {
 
 	dst.Spec.Traps = trapsv2
 
+	if err := restoreConversionData(dst); err != nil {
+		return fmt.Errorf("ConvertTo Error: %w", err)
+	}
+
+	dst.Status.ObservedGeneration = src.Status.ObservedGeneration
+	dst.Status.Armed = src.Status.Armed
+	dst.Status.Missing = src.Status.Missing
+	for _, condition := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, *condition.DeepCopy())
+	}
+	for _, trapStatus := range src.Status.Traps {
+
+		trapStatusv2 := v2alpha1.KiveTrapStatus{}
+		trapStatusv2.ID = trapStatus.ID
+		trapStatusv2.Path = trapStatus.Path
+
+		for _, containerStatus := range trapStatus.Containers {
+			trapStatusv2.Containers = append(trapStatusv2.Containers, v2alpha1.KiveTrapContainerStatus{
+				Namespace:    containerStatus.Namespace,
+				Pod:          containerStatus.Pod,
+				Container:    containerStatus.Container,
+				Node:         containerStatus.Node,
+				State:        v2alpha1.KiveTrapState(containerStatus.State),
+				Inode:        containerStatus.Inode,
+				Dev:          containerStatus.Dev,
+				LastError:    containerStatus.LastError,
+				LastReplaced: containerStatus.LastReplaced.DeepCopy(),
+			})
+		}
+
+		dst.Status.Traps = append(dst.Status.Traps, trapStatusv2)
+	}
+
 	return nil
 }
 
@@ -104,5 +140,88 @@ func (dst *KivePolicy) ConvertFrom(srcRaw conversion.Hub) error {
 
 	dst.Spec.Traps = trapsv1
 
+	// The fields that v1 does not have are kept in an annotation
+	data, err := json.Marshal(src.Spec)
+	if err != nil {
+		return fmt.Errorf("ConvertFrom Error Json Marshal spec: %w", err)
+	}
+	if dst.Annotations == nil {
+		dst.Annotations = map[string]string{}
+	}
+	dst.Annotations[ConversionDataAnnotation] = string(data)
+
+	dst.Status.ObservedGeneration = src.Status.ObservedGeneration
+	dst.Status.Armed = src.Status.Armed
+	dst.Status.Missing = src.Status.Missing
+	for _, condition := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, *condition.DeepCopy())
+	}
+	for _, trapStatus := range src.Status.Traps {
+
+		trapStatusv1 := KiveTrapStatus{}
+		trapStatusv1.ID = trapStatus.ID
+		trapStatusv1.Path = trapStatus.Path
+
+		for _, containerStatus := range trapStatus.Containers {
+			trapStatusv1.Containers = append(trapStatusv1.Containers, KiveTrapContainerStatus{
+				Namespace:    containerStatus.Namespace,
+				Pod:          containerStatus.Pod,
+				Container:    containerStatus.Container,
+				Node:         containerStatus.Node,
+				State:        string(containerStatus.State),
+				Inode:        containerStatus.Inode,
+				Dev:          containerStatus.Dev,
+				LastError:    containerStatus.LastError,
+				LastReplaced: containerStatus.LastReplaced.DeepCopy(),
+			})
+		}
+
+		dst.Status.Traps = append(dst.Status.Traps, trapStatusv1)
+	}
+
+	return nil
+}
+
+/*
+ *  Restore the fields that v1 does not have from the annotation set
+ *  by ConvertFrom, then remove It. The fields of a trap are restored
+ *  only if Its path did not change in v1.
+ */
+func restoreConversionData(dst *v2alpha1.KivePolicy) error {
+
+	data, ok := dst.Annotations[ConversionDataAnnotation]
+	if !ok {
+		return nil
+	}
+	delete(dst.Annotations, ConversionDataAnnotation)
+	if len(dst.Annotations) == 0 {
+		dst.Annotations = nil
+	}
+
+	restored := v2alpha1.KivePolicySpec{}
+	if err := json.Unmarshal([]byte(data), &restored); err != nil {
+		return fmt.Errorf("restoreConversionData Error Json Unmarshal: %w", err)
+	}
+
+	dst.Spec.Sinks = restored.Sinks
+	for i := range dst.Spec.Traps {
+
+		if i >= len(restored.Traps) || restored.Traps[i].Path != dst.Spec.Traps[i].Path {
+			continue
+		}
+
+		trap := &dst.Spec.Traps[i]
+		trap.Recursive = restored.Traps[i].Recursive
+		trap.MaxDepth = restored.Traps[i].MaxDepth
+		trap.CallbackAuth = restored.Traps[i].CallbackAuth
+		trap.Sinks = restored.Traps[i].Sinks
+		trap.DedupWindow = restored.Traps[i].DedupWindow
+		trap.RateLimit = restored.Traps[i].RateLimit
+		trap.Access = restored.Traps[i].Access
+		trap.Allow = restored.Traps[i].Allow
+		trap.Action = restored.Traps[i].Action
+		trap.DryRun = restored.Traps[i].DryRun
+	}
+
 	return nil
 }
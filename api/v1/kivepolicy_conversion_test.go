@@ -0,0 +1,135 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+package v1
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+func newTestKivePolicy() *v2alpha1.KivePolicy {
+
+	return &v2alpha1.KivePolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "policy",
+			Namespace:   "kive-test",
+			Annotations: map[string]string{"team": "kive"},
+		},
+		Spec: v2alpha1.KivePolicySpec{
+			AlertVersion: "v1",
+			Sinks: []v2alpha1.KiveSink{{
+				Name: "history",
+				Type: "record",
+				TTL:  &metav1.Duration{Duration: time.Hour},
+			}},
+			Traps: []v2alpha1.KiveTrap{{
+				Path:      "/root/.ssh",
+				Recursive: true,
+				MaxDepth:  2,
+				Callback:  "https://collector.example.com/ingest",
+				CallbackAuth: &v2alpha1.KiveSinkAuth{
+					HMACSecretRef: &v2alpha1.KiveSecretKeyRef{Name: "hmac", Key: "key"},
+				},
+				Sinks:       []string{"history"},
+				Metadata:    map[string]string{"severity": "critical"},
+				DedupWindow: &metav1.Duration{Duration: time.Minute},
+				RateLimit: &v2alpha1.KiveRateLimit{
+					Burst:    10,
+					Interval: metav1.Duration{Duration: time.Second},
+				},
+				Access: []v2alpha1.KiveAccess{v2alpha1.KiveAccessRead},
+				Allow:  &v2alpha1.KiveTrapAllow{Binaries: []string{"sshd"}},
+				Action: v2alpha1.KiveTrapActionDeny,
+				DryRun: true,
+				MatchAny: []v2alpha1.KiveTrapMatch{{
+					Namespace: "default",
+				}},
+			}},
+		},
+	}
+}
+
+func TestKivePolicyRoundTrip(t *testing.T) {
+
+	hub := newTestKivePolicy()
+
+	spoke := &KivePolicy{}
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := spoke.Annotations[ConversionDataAnnotation]; !ok {
+		t.Fatalf("ConvertFrom did not set the %s annotation", ConversionDataAnnotation)
+	}
+
+	converted := &v2alpha1.KivePolicy{}
+	if err := spoke.ConvertTo(converted); err != nil {
+		t.Fatal(err)
+	}
+
+	if !equality.Semantic.DeepEqual(hub, converted) {
+		t.Errorf("round trip changed the KivePolicy:\n got %+v\nwant %+v", converted, hub)
+	}
+}
+
+func TestKivePolicyRoundTripChangedPath(t *testing.T) {
+
+	hub := newTestKivePolicy()
+
+	spoke := &KivePolicy{}
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	// Edited by a v1 client
+	spoke.Spec.Traps[0].Path = "/etc/shadow"
+
+	converted := &v2alpha1.KivePolicy{}
+	if err := spoke.ConvertTo(converted); err != nil {
+		t.Fatal(err)
+	}
+
+	trap := converted.Spec.Traps[0]
+	if trap.Path != "/etc/shadow" || trap.Recursive || trap.Action != "" || trap.Allow != nil {
+		t.Errorf("the fields of the replaced trap were restored: %+v", trap)
+	}
+	if !equality.Semantic.DeepEqual(converted.Spec.Sinks, hub.Spec.Sinks) {
+		t.Errorf("sinks %+v, want %+v", converted.Spec.Sinks, hub.Spec.Sinks)
+	}
+	if _, ok := converted.Annotations[ConversionDataAnnotation]; ok {
+		t.Errorf("ConvertTo kept the %s annotation", ConversionDataAnnotation)
+	}
+}
+
+func TestKivePolicyFromV1(t *testing.T) {
+
+	spoke := &KivePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "kive-test"},
+		Spec: KivePolicySpec{
+			Traps: []KiveTrap{{Path: "/etc/passwd"}},
+		},
+	}
+
+	converted := &v2alpha1.KivePolicy{}
+	if err := spoke.ConvertTo(converted); err != nil {
+		t.Fatal(err)
+	}
+	if converted.Annotations != nil {
+		t.Errorf("annotations %v, want none", converted.Annotations)
+	}
+	if len(converted.Spec.Traps) != 1 || converted.Spec.Traps[0].Path != "/etc/passwd" {
+		t.Errorf("traps %+v, want /etc/passwd", converted.Spec.Traps)
+	}
+}
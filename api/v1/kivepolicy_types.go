@@ -23,14 +23,77 @@ type KivePolicySpec struct {
 	Traps []KiveTrap `json:"traps,omitempty"`
 }
 
+// KivePolicyStatus defines the observed state of KivePolicy
+type KivePolicyStatus struct {
+	// Generation of the KivePolicy observed by the conditions
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Ready and Degraded conditions
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Number of containers where a trap is armed
+	Armed int32 `json:"armed,omitempty"`
+	// Number of containers where a trap could not be armed
+	Missing int32 `json:"missing,omitempty"`
+	// Status of each trap
+	// +listType=map
+	// +listMapKey=id
+	Traps []KiveTrapStatus `json:"traps,omitempty"`
+}
+
+// Observed state of a trap in the containers It matches
+type KiveTrapStatus struct {
+	// Identifier of the trap, the same as the trap-id label of Its
+	// KiveData
+	ID string `json:"id"`
+	// Path monitored by the trap
+	Path string `json:"path,omitempty"`
+	// Containers matched by the trap. Each node of the cluster reports
+	// the containers running on It
+	// +listType=map
+	// +listMapKey=namespace
+	// +listMapKey=pod
+	// +listMapKey=container
+	Containers []KiveTrapContainerStatus `json:"containers,omitempty"`
+}
+
+// Observed state of a trap in a container
+type KiveTrapContainerStatus struct {
+	// Namespace of the pod
+	Namespace string `json:"namespace"`
+	// Name of the pod
+	Pod string `json:"pod"`
+	// Name of the container
+	Container string `json:"container"`
+	// Node where the pod is running
+	Node string `json:"node,omitempty"`
+	// One of "Found", "Created", "Missing" or "Replaced"
+	State string `json:"state"`
+	// (optional) Inode of the traced file
+	Inode uint64 `json:"inode,omitempty"`
+	// (optional) Device of the traced file
+	Dev uint32 `json:"dev,omitempty"`
+	// (optional) Last error while arming the trap in this container
+	LastError string `json:"lastError,omitempty"`
+	// (optional) Last time a trapped file was found replaced by
+	// another one in this container
+	LastReplaced *metav1.Time `json:"lastReplaced,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Degraded",type=string,JSONPath=`.status.conditions[?(@.type=="Degraded")].status`
+// +kubebuilder:printcolumn:name="Armed",type=integer,JSONPath=`.status.armed`
+// +kubebuilder:printcolumn:name="Missing",type=integer,JSONPath=`.status.missing`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 type KivePolicy struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec KivePolicySpec `json:"spec,omitempty"`
+	Spec   KivePolicySpec   `json:"spec,omitempty"`
+	Status KivePolicyStatus `json:"status,omitempty"`
 }
 
 // +kubebuilder:object:root=true
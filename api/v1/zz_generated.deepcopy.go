@@ -17,6 +17,7 @@
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -162,6 +163,7 @@ func (in *KivePolicy) DeepCopyInto(out *KivePolicy) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KivePolicy.
@@ -236,6 +238,35 @@ func (in *KivePolicySpec) DeepCopy() *KivePolicySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KivePolicyStatus) DeepCopyInto(out *KivePolicyStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Traps != nil {
+		in, out := &in.Traps, &out.Traps
+		*out = make([]KiveTrapStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KivePolicyStatus.
+func (in *KivePolicyStatus) DeepCopy() *KivePolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KivePolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KiveTrap) DeepCopyInto(out *KiveTrap) {
 	*out = *in
@@ -265,6 +296,25 @@ func (in *KiveTrap) DeepCopy() *KiveTrap {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KiveTrapContainerStatus) DeepCopyInto(out *KiveTrapContainerStatus) {
+	*out = *in
+	if in.LastReplaced != nil {
+		in, out := &in.LastReplaced, &out.LastReplaced
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KiveTrapContainerStatus.
+func (in *KiveTrapContainerStatus) DeepCopy() *KiveTrapContainerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KiveTrapContainerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KiveTrapMatch) DeepCopyInto(out *KiveTrapMatch) {
 	*out = *in
@@ -287,6 +337,28 @@ func (in *KiveTrapMatch) DeepCopy() *KiveTrapMatch {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KiveTrapStatus) DeepCopyInto(out *KiveTrapStatus) {
+	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]KiveTrapContainerStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KiveTrapStatus.
+func (in *KiveTrapStatus) DeepCopy() *KiveTrapStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KiveTrapStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeMetadata) DeepCopyInto(out *NodeMetadata) {
 	*out = *in
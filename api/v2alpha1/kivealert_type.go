@@ -16,6 +16,19 @@ var (
 	SupportedKiveAlertVersions = []string{"v1", "v2alpha1"}
 )
 
+// Operations on a trapped file reported in the KiveAlerts
+const (
+	// The permissions of the file were checked, for example to read
+	// or write It
+	KiveOperationAccess = "access"
+	// A link to the file was removed
+	KiveOperationUnlink = "unlink"
+	// The file was renamed, or replaced by renaming another file
+	KiveOperationRename = "rename"
+	// The attributes of the file, like Its mode or owner, were changed
+	KiveOperationSetattr = "setattr"
+)
+
 // Information about the container
 type ContainerMetadata struct {
 	// Container id
@@ -44,19 +57,27 @@ type NodeMetadata struct {
 
 // Information related to the process that accessed the file
 type ProcessMetadata struct {
-	// Process ID
+	// Process ID on the host
 	Pid int32 `json:"pid"`
-	// Thread group ID
+	// Thread group ID on the host
 	Tgid uint32 `json:"tgid"`
+	// Process ID in the pid namespace of the container, as seen
+	// inside the pod
+	NsPid int32 `json:"ns-pid,omitempty"`
+	// Thread group ID in the pid namespace of the container
+	NsTgid uint32 `json:"ns-tgid,omitempty"`
 	// User ID
 	Uid uint32 `json:"uid"`
 	// Group ID
 	Gid uint32 `json:"gid"`
-	// Process binary
+	// Process binary, the name of the executable truncated to 15
+	// characters
 	Binary string `json:"binary"`
-	// Current Working Directory
+	// Full path of the executable in the container
+	Executable string `json:"executable,omitempty"`
+	// Current Working Directory in the container
 	Cwd string `json:"cwd"`
-	// Arguments to the Binary
+	// Arguments to the Binary, separated by spaces
 	Arguments string `json:"arguments"`
 }
 
@@ -66,8 +87,12 @@ type KiveAlertMetadata struct {
 	Path string `json:"path"`
 	// Inode number of the file
 	Inode uint64 `json:"inode"`
-	// Unix access permission mask
+	// Unix access permission mask. For the setattr operation, the
+	// ATTR_* bits of the changed attributes
 	Mask int32 `json:"mask"`
+	// Operation on the file, one of "access", "unlink", "rename" or
+	// "setattr"
+	Operation string `json:"operation,omitempty"`
 	// ID of the kernel where the alert was triggered
 	KernelID string `json:"kernel-id"`
 	// Callback URI
@@ -92,4 +117,23 @@ type KiveAlert struct {
 	Node NodeMetadata `json:"node"`
 	// Information about the process that accessed the file
 	Process ProcessMetadata `json:"process"`
+	// (optional) Number of accesses aggregated in this alert, set if
+	// the trap has a dedup window
+	Count int32 `json:"count,omitempty"`
+	// (optional) Time of the first aggregated access
+	FirstSeen string `json:"first-seen,omitempty"` // RFC 3339
+	// (optional) Time of the last aggregated access
+	LastSeen string `json:"last-seen,omitempty"` // RFC 3339
+	// (optional) Number of accesses of the process to the file that
+	// were suppressed by the eBPF program since Its previous alert
+	Suppressed uint32 `json:"suppressed,omitempty"`
+	// (optional) Number of eBPF events lost on the node because the
+	// ring buffer was full. Set only in the alerts reporting the
+	// lost events, which have no file, pod or process
+	Lost uint64 `json:"lost,omitempty"`
+	// (optional) The access was denied by a trap with the deny action
+	Blocked bool `json:"blocked,omitempty"`
+	// (optional) The access would have been denied by a trap with the
+	// deny action in dry-run mode, but was allowed
+	WouldBlock bool `json:"would-block,omitempty"`
 }
@@ -0,0 +1,57 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package v2alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Labels of the KiveAlertRecords, used to enforce the retention
+	// cap of each record sink
+	KiveAlertRecordPolicyLabel = "kivebpf.san7o.github.io/policy"
+	KiveAlertRecordSinkLabel   = "kivebpf.san7o.github.io/sink"
+)
+
+// A KiveAlert stored by a record sink in the namespace of the
+// KivePolicy that generated It. The record is owned by the
+// KivePolicy and is deleted with It, or when It expires.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=kivealert;kivealerts
+// +kubebuilder:printcolumn:name="Policy",type=string,JSONPath=`.alert.kive-policy-name`
+// +kubebuilder:printcolumn:name="Path",type=string,JSONPath=`.alert.metadata.path`
+// +kubebuilder:printcolumn:name="Pod",type=string,JSONPath=`.alert.pod.name`
+// +kubebuilder:printcolumn:name="Binary",type=string,JSONPath=`.alert.process.binary`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type KiveAlertRecord struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// The stored alert
+	Alert KiveAlert `json:"alert"`
+	// (optional) Time after which the record is garbage collected
+	ExpireTime *metav1.Time `json:"expireTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+type KiveAlertRecordList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KiveAlertRecord `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KiveAlertRecord{}, &KiveAlertRecordList{})
+}
@@ -23,11 +23,99 @@ type KivePolicySpec struct {
 	AlertVersion string `json:"alertVersion,omitempty"`
 	// List of traps
 	Traps []KiveTrap `json:"traps,omitempty"`
+	// (optional) Alert destinations that traps can reference by name
+	Sinks []KiveSink `json:"sinks,omitempty"`
+}
+
+// Condition types of a KivePolicy
+const (
+	// Every trap is armed in at least one container and no matched
+	// container is missing the traced file
+	KivePolicyReady = "Ready"
+	// Some traps could not be armed in some of the matched containers
+	KivePolicyDegraded = "Degraded"
+)
+
+// State of a trap in a container
+type KiveTrapState string
+
+const (
+	// The file was found in the container
+	KiveTrapFound KiveTrapState = "Found"
+	// The file was not found and has been created by the operator
+	KiveTrapCreated KiveTrapState = "Created"
+	// The file could not be found nor created, see lastError
+	KiveTrapMissing KiveTrapState = "Missing"
+	// The file was replaced by another one since the previous
+	// reconciliation, the trap has been armed on the new file
+	KiveTrapReplaced KiveTrapState = "Replaced"
+)
+
+// KivePolicyStatus defines the observed state of KivePolicy
+type KivePolicyStatus struct {
+	// Generation of the KivePolicy observed by the conditions
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Ready and Degraded conditions
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Number of containers where a trap is armed
+	Armed int32 `json:"armed,omitempty"`
+	// Number of containers where a trap could not be armed
+	Missing int32 `json:"missing,omitempty"`
+	// Status of each trap
+	// +listType=map
+	// +listMapKey=id
+	Traps []KiveTrapStatus `json:"traps,omitempty"`
+}
+
+// Observed state of a trap in the containers It matches
+type KiveTrapStatus struct {
+	// Identifier of the trap, the same as the trap-id label of Its
+	// KiveData
+	ID string `json:"id"`
+	// Path monitored by the trap
+	Path string `json:"path,omitempty"`
+	// Containers matched by the trap. Each node of the cluster reports
+	// the containers running on It
+	// +listType=map
+	// +listMapKey=namespace
+	// +listMapKey=pod
+	// +listMapKey=container
+	Containers []KiveTrapContainerStatus `json:"containers,omitempty"`
+}
+
+// Observed state of a trap in a container
+type KiveTrapContainerStatus struct {
+	// Namespace of the pod
+	Namespace string `json:"namespace"`
+	// Name of the pod
+	Pod string `json:"pod"`
+	// Name of the container
+	Container string `json:"container"`
+	// Node where the pod is running
+	Node string `json:"node,omitempty"`
+	// One of "Found", "Created", "Missing" or "Replaced"
+	State KiveTrapState `json:"state"`
+	// (optional) Inode of the traced file
+	Inode uint64 `json:"inode,omitempty"`
+	// (optional) Device of the traced file
+	Dev uint32 `json:"dev,omitempty"`
+	// (optional) Last error while arming the trap in this container
+	LastError string `json:"lastError,omitempty"`
+	// (optional) Last time a trapped file was found replaced by
+	// another one in this container
+	LastReplaced *metav1.Time `json:"lastReplaced,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Degraded",type=string,JSONPath=`.status.conditions[?(@.type=="Degraded")].status`
+// +kubebuilder:printcolumn:name="Armed",type=integer,JSONPath=`.status.armed`
+// +kubebuilder:printcolumn:name="Missing",type=integer,JSONPath=`.status.missing`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 //// +kubebuilder:webhook:path=/mutate-kive-kivepolicy,mutating=true,failurePolicy=fail,groups=kivebpf.san7o.github.io,resources=kivepolicies,verbs=create;update,versions=v1;v2alpha1,name=mutate.kivepolicy.kivebpf.san7o.github.io,admissionReviewVersions=v1,sideEffects=none
 //// +kubebuilder:webhook:path=/validate-kive-kivepolicy,mutating=false,failurePolicy=fail,groups=kivebpf.san7o.github.io,resources=kivepolivies,verbs=create;update,versions=v1;v2alpha1,name=validate.kivepolicy.kivebpf.san7o.github.io,sideEffects=None,admissionReviewVersions=v1
 
@@ -35,7 +123,8 @@ type KivePolicy struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec KivePolicySpec `json:"spec,omitempty"`
+	Spec   KivePolicySpec   `json:"spec,omitempty"`
+	Status KivePolicyStatus `json:"status,omitempty"`
 }
 
 func (*KivePolicy) Hub() {}
@@ -0,0 +1,76 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package v2alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// A destination where KiveAlerts are sent. Sinks are declared once
+// in the KivePolicy and referenced by name from the traps.
+type KiveSink struct {
+	// Name of the sink, used by traps to reference It
+	Name string `json:"name"`
+	// Type of the sink, one of "webhook", "file", "syslog", "stdout"
+	// or "record"
+	Type string `json:"type"`
+	// (optional) Endpoint of a webhook sink
+	URL string `json:"url,omitempty"`
+	// (optional) Path of the file where a file sink appends alerts,
+	// inside the operator's container. It must be in the root
+	// directory of the file sinks of the operator, or relative to It
+	Path string `json:"path,omitempty"`
+	// (optional) Network of a syslog sink, such as "udp" or "tcp". If
+	// empty, the local syslog daemon is used
+	Network string `json:"network,omitempty"`
+	// (optional) Address of a remote syslog daemon
+	Address string `json:"address,omitempty"`
+	// (optional) Tag of the syslog messages
+	Tag string `json:"tag,omitempty"`
+	// (optional) Authentication of a webhook sink
+	Auth *KiveSinkAuth `json:"auth,omitempty"`
+	// (optional) Namespace where a record sink stores the
+	// KiveAlertRecords. It defaults to, and must be equal to, the
+	// namespace of the KivePolicy
+	Namespace string `json:"namespace,omitempty"`
+	// (optional) Time after which the KiveAlertRecords of a record
+	// sink are deleted, 24h if empty
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+	// (optional) Maximum number of KiveAlertRecords kept by a record
+	// sink, the oldest ones are deleted by the leader every
+	// --record-gc-interval. 1000 if 0
+	MaxRecords int32 `json:"maxRecords,omitempty"`
+}
+
+// Authentication of the alerts sent to a webhook
+type KiveSinkAuth struct {
+	// (optional) Namespace of the referenced Secrets. It defaults to,
+	// and must be equal to, the namespace of the KivePolicy
+	Namespace string `json:"namespace,omitempty"`
+	// (optional) Sign the body of each request with HMAC-SHA256 using
+	// the key stored in this Secret
+	HMACSecretRef *KiveSecretKeyRef `json:"hmacSecretRef,omitempty"`
+	// (optional) Name of a Secret of type kubernetes.io/tls with the
+	// client certificate ("tls.crt" and "tls.key") presented to the
+	// endpoint. If the Secret contains "ca.crt", It is used to verify
+	// the certificate of the endpoint
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+}
+
+// Reference to a key of a Secret
+type KiveSecretKeyRef struct {
+	// Name of the Secret
+	Name string `json:"name"`
+	// (optional) Key of the Secret, "hmac-key" if empty
+	Key string `json:"key,omitempty"`
+}
@@ -12,21 +12,93 @@
 
 package v2alpha1
 
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 type KiveTrap struct {
 	// Specifies which path to monitor
+	//  - if this field is prepended by "glob:", the rest of the string
+	//    is a filesystem-style pattern, as described in go
+	//    filepath.Match library, and every matching file is trapped
+	//  - if this field is prepended by "regex:", the rest of the
+	//    string is a regular expression that must match the whole
+	//    path, and every matching file is trapped
+	//  - otherwise, the path is literal
 	Path string `json:"path,omitempty"`
 	// (optional) Whether to create the file or not if It was not found
 	Create bool `json:"create,omitempty"`
 	// (optional) The permissions of the file to be created if create is set to true
 	Mode uint32 `json:"mode,omitempty"`
-	// (optional) Send an HTTP POST request to this endpoint
+	// (optional) If path is a directory, also trap the files and the
+	// directories below It, up to maxDepth levels. The files created
+	// later are trapped only when the directory is scanned again,
+	// every --rescan-interval of the operator (default 10m): the
+	// accesses to a new file are not alerted until then
+	Recursive bool `json:"recursive,omitempty"`
+	// (optional) Number of levels below path trapped by a recursive
+	// trap, 1 traps only the entries of the directory. Defaults to 8
+	// +kubebuilder:validation:Minimum=1
+	MaxDepth int32 `json:"maxDepth,omitempty"`
+	// (optional) Send an HTTP POST request to this endpoint. This is
+	// equivalent to referencing a webhook sink with this url
 	Callback string `json:"callback,omitempty"`
+	// (optional) Authentication of the requests sent to the callback
+	CallbackAuth *KiveSinkAuth `json:"callbackAuth,omitempty"`
+	// (optional) Names of the sinks, declared in the KivePolicy, where
+	// the alerts generated by this trap are sent
+	Sinks []string `json:"sinks,omitempty"`
 	// (optional) Additional information for this trap
 	Metadata map[string]string `json:"metadata,omitempty"`
+	// (optional) Aggregate the accesses of the same process to the
+	// same file with the same mask within this window. The first
+	// access is alerted immediately, the following ones are sent as
+	// a single alert with their count when the window ends
+	DedupWindow *metav1.Duration `json:"dedupWindow,omitempty"`
+	// (optional) Limit the number of alerts sent by this trap, the
+	// alerts beyond the limit are dropped
+	RateLimit *KiveRateLimit `json:"rateLimit,omitempty"`
+	// (optional) Alert only on these kinds of access, by default any
+	// access is alerted. Unless access is listed, the checks done by
+	// the access(2) syscall are ignored
+	Access []KiveAccess `json:"access,omitempty"`
+	// (optional) Processes whose accesses are not alerted
+	Allow *KiveTrapAllow `json:"allow,omitempty"`
+	// (optional) What to do on the accesses matching access, alert by
+	// default. With deny, the accesses fail with EPERM and the alerts
+	// are marked as blocked. Denying requires BPF LSM, otherwise the
+	// accesses are only alerted
+	Action KiveTrapAction `json:"action,omitempty"`
+	// (optional) With the deny action, do not deny the accesses but
+	// mark the alerts with would-block
+	DryRun bool `json:"dryRun,omitempty"`
 	// Match any of the following items (logical OR), at least one must be present
 	MatchAny []KiveTrapMatch `json:"matchAny,omitempty"`
 }
 
+// A kind of access to a file, as checked by the kernel
+// +kubebuilder:validation:Enum=read;write;exec;append;open;access
+type KiveAccess string
+
+const (
+	KiveAccessRead   KiveAccess = "read"
+	KiveAccessWrite  KiveAccess = "write"
+	KiveAccessExec   KiveAccess = "exec"
+	KiveAccessAppend KiveAccess = "append"
+	KiveAccessOpen   KiveAccess = "open"
+	// Permission check of the access(2) syscall
+	KiveAccessAccess KiveAccess = "access"
+)
+
+// What a trap does when Its file is accessed
+// +kubebuilder:validation:Enum=alert;deny
+type KiveTrapAction string
+
+const (
+	KiveTrapActionAlert KiveTrapAction = "alert"
+	KiveTrapActionDeny  KiveTrapAction = "deny"
+)
+
 // Match all the following optional fields (logical AND)
 type KiveTrapMatch struct {
 	// Filter pods by name
@@ -47,3 +119,29 @@ type KiveTrapMatch struct {
 	// Filter pods by label
 	MatchLabels map[string]string `json:"matchLabels,omitempty"`
 }
+
+// An access is not alerted if the process matches any of the
+// following fields
+type KiveTrapAllow struct {
+	// (optional) Names of the executables, as reported in the binary
+	// field of the alerts. The kernel truncates the names to 15
+	// characters, so only the first 15 are compared
+	Binaries []string `json:"binaries,omitempty"`
+	// (optional) User IDs, as seen by the node
+	Uids []uint32 `json:"uids,omitempty"`
+	// (optional) Group IDs, as seen by the node
+	Gids []uint32 `json:"gids,omitempty"`
+	// (optional) Process IDs in the pid namespace of the process, 1
+	// is the entrypoint of the container
+	Pids []uint32 `json:"pids,omitempty"`
+}
+
+// Token bucket: a burst of Burst alerts is allowed, then one alert
+// every Interval
+type KiveRateLimit struct {
+	// Number of alerts that can be sent at once
+	// +kubebuilder:validation:Minimum=1
+	Burst int32 `json:"burst"`
+	// Time needed to allow one more alert
+	Interval metav1.Duration `json:"interval"`
+}
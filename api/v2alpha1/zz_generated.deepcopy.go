@@ -17,6 +17,7 @@
 package v2alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -76,6 +77,68 @@ func (in *KiveAlertMetadata) DeepCopy() *KiveAlertMetadata {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KiveAlertRecord) DeepCopyInto(out *KiveAlertRecord) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Alert.DeepCopyInto(&out.Alert)
+	if in.ExpireTime != nil {
+		in, out := &in.ExpireTime, &out.ExpireTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KiveAlertRecord.
+func (in *KiveAlertRecord) DeepCopy() *KiveAlertRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(KiveAlertRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KiveAlertRecord) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KiveAlertRecordList) DeepCopyInto(out *KiveAlertRecordList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KiveAlertRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KiveAlertRecordList.
+func (in *KiveAlertRecordList) DeepCopy() *KiveAlertRecordList {
+	if in == nil {
+		return nil
+	}
+	out := new(KiveAlertRecordList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KiveAlertRecordList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KiveData) DeepCopyInto(out *KiveData) {
 	*out = *in
@@ -162,6 +225,7 @@ func (in *KivePolicy) DeepCopyInto(out *KivePolicy) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KivePolicy.
@@ -224,6 +288,13 @@ func (in *KivePolicySpec) DeepCopyInto(out *KivePolicySpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Sinks != nil {
+		in, out := &in.Sinks, &out.Sinks
+		*out = make([]KiveSink, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KivePolicySpec.
@@ -236,9 +307,124 @@ func (in *KivePolicySpec) DeepCopy() *KivePolicySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KivePolicyStatus) DeepCopyInto(out *KivePolicyStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Traps != nil {
+		in, out := &in.Traps, &out.Traps
+		*out = make([]KiveTrapStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KivePolicyStatus.
+func (in *KivePolicyStatus) DeepCopy() *KivePolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KivePolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KiveRateLimit) DeepCopyInto(out *KiveRateLimit) {
+	*out = *in
+	out.Interval = in.Interval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KiveRateLimit.
+func (in *KiveRateLimit) DeepCopy() *KiveRateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(KiveRateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KiveSecretKeyRef) DeepCopyInto(out *KiveSecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KiveSecretKeyRef.
+func (in *KiveSecretKeyRef) DeepCopy() *KiveSecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(KiveSecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KiveSink) DeepCopyInto(out *KiveSink) {
+	*out = *in
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(KiveSinkAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KiveSink.
+func (in *KiveSink) DeepCopy() *KiveSink {
+	if in == nil {
+		return nil
+	}
+	out := new(KiveSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KiveSinkAuth) DeepCopyInto(out *KiveSinkAuth) {
+	*out = *in
+	if in.HMACSecretRef != nil {
+		in, out := &in.HMACSecretRef, &out.HMACSecretRef
+		*out = new(KiveSecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KiveSinkAuth.
+func (in *KiveSinkAuth) DeepCopy() *KiveSinkAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(KiveSinkAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KiveTrap) DeepCopyInto(out *KiveTrap) {
 	*out = *in
+	if in.CallbackAuth != nil {
+		in, out := &in.CallbackAuth, &out.CallbackAuth
+		*out = new(KiveSinkAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Sinks != nil {
+		in, out := &in.Sinks, &out.Sinks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Metadata != nil {
 		in, out := &in.Metadata, &out.Metadata
 		*out = make(map[string]string, len(*in))
@@ -246,6 +432,26 @@ func (in *KiveTrap) DeepCopyInto(out *KiveTrap) {
 			(*out)[key] = val
 		}
 	}
+	if in.DedupWindow != nil {
+		in, out := &in.DedupWindow, &out.DedupWindow
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(KiveRateLimit)
+		**out = **in
+	}
+	if in.Access != nil {
+		in, out := &in.Access, &out.Access
+		*out = make([]KiveAccess, len(*in))
+		copy(*out, *in)
+	}
+	if in.Allow != nil {
+		in, out := &in.Allow, &out.Allow
+		*out = new(KiveTrapAllow)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.MatchAny != nil {
 		in, out := &in.MatchAny, &out.MatchAny
 		*out = make([]KiveTrapMatch, len(*in))
@@ -265,6 +471,60 @@ func (in *KiveTrap) DeepCopy() *KiveTrap {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KiveTrapAllow) DeepCopyInto(out *KiveTrapAllow) {
+	*out = *in
+	if in.Binaries != nil {
+		in, out := &in.Binaries, &out.Binaries
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Uids != nil {
+		in, out := &in.Uids, &out.Uids
+		*out = make([]uint32, len(*in))
+		copy(*out, *in)
+	}
+	if in.Gids != nil {
+		in, out := &in.Gids, &out.Gids
+		*out = make([]uint32, len(*in))
+		copy(*out, *in)
+	}
+	if in.Pids != nil {
+		in, out := &in.Pids, &out.Pids
+		*out = make([]uint32, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KiveTrapAllow.
+func (in *KiveTrapAllow) DeepCopy() *KiveTrapAllow {
+	if in == nil {
+		return nil
+	}
+	out := new(KiveTrapAllow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KiveTrapContainerStatus) DeepCopyInto(out *KiveTrapContainerStatus) {
+	*out = *in
+	if in.LastReplaced != nil {
+		in, out := &in.LastReplaced, &out.LastReplaced
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KiveTrapContainerStatus.
+func (in *KiveTrapContainerStatus) DeepCopy() *KiveTrapContainerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KiveTrapContainerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KiveTrapMatch) DeepCopyInto(out *KiveTrapMatch) {
 	*out = *in
@@ -287,6 +547,28 @@ func (in *KiveTrapMatch) DeepCopy() *KiveTrapMatch {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KiveTrapStatus) DeepCopyInto(out *KiveTrapStatus) {
+	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]KiveTrapContainerStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KiveTrapStatus.
+func (in *KiveTrapStatus) DeepCopy() *KiveTrapStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KiveTrapStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeMetadata) DeepCopyInto(out *NodeMetadata) {
 	*out = *in
@@ -13,10 +13,21 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"flag"
-	"net/http"
+	"fmt"
 	"io"
-	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -24,32 +35,183 @@ import (
 )
 
 const (
-	DefaultPort = "8090"
+	DefaultPort    = "8090"
+	DefaultMaxSkew = 5 * time.Minute
+
+	// Must match the headers set by the operator's webhook sink
+	SignatureHeader = "X-Kive-Signature"
+	TimestampHeader = "X-Kive-Timestamp"
+	NonceHeader     = "X-Kive-Nonce"
+	SignaturePrefix = "sha256="
 )
 
-func ingestHandler(w http.ResponseWriter, r *http.Request) {
-	log := log.FromContext(r.Context())
-	
-	bytes, err := io.ReadAll(r.Body)
+// Verifies the signature of the requests and rejects replayed ones.
+// Nonces are remembered for twice the allowed skew, after which the
+// timestamp check alone rejects the request.
+type verifier struct {
+	key     []byte
+	maxSkew time.Duration
+
+	mutex  sync.Mutex
+	nonces map[string]time.Time
+}
+
+func (self *verifier) verify(r *http.Request, body []byte) error {
+
+	signature := r.Header.Get(SignatureHeader)
+	timestamp := r.Header.Get(TimestampHeader)
+	nonce := r.Header.Get(NonceHeader)
+	if signature == "" || timestamp == "" || nonce == "" {
+		return fmt.Errorf("unsigned request")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %s", timestamp)
+	}
+	skew := time.Since(time.Unix(seconds, 0))
+	if skew > self.maxSkew || skew < -self.maxSkew {
+		return fmt.Errorf("stale request, timestamp %s", timestamp)
+	}
+
+	mac := hmac.New(sha256.New, self.key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	expected := SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	now := time.Now()
+	for seen, expiry := range self.nonces {
+		if now.After(expiry) {
+			delete(self.nonces, seen)
+		}
+	}
+	if _, ok := self.nonces[nonce]; ok {
+		return fmt.Errorf("replayed nonce %s", nonce)
+	}
+	self.nonces[nonce] = now.Add(2 * self.maxSkew)
+
+	return nil
+}
+
+// The whitespace around the key is not part of It, like the newline
+// at the end of the files of kubectl create secret --from-file. The
+// operator trims It the same way, see HMACKey in the sink package.
+func readHMACKey(path string) ([]byte, error) {
+
+	key, err := os.ReadFile(path)
 	if err != nil {
-		log.Error(err, "error reading request's body")
+		return nil, err
+	}
+	key = bytes.TrimSpace(key)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("empty HMAC key in %s", path)
+	}
+
+	return key, nil
+}
+
+func ingestHandler(verifier *verifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.FromContext(r.Context())
+
+		bytes, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err, "error reading request's body")
+			http.Error(w, "cannot read body", http.StatusBadRequest)
+			return
+		}
+
+		if verifier != nil {
+			if err := verifier.verify(r, bytes); err != nil {
+				log.Info("rejected request", "reason", err.Error(), "remote", r.RemoteAddr)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		log.Info("received request", "body", string(bytes))
 	}
-	log.Info("received request", "body", string(bytes))
 }
 
 func main() {
 
 	var portFlag = flag.String("port", DefaultPort, "port to listen to")
+	var hmacKeyFile = flag.String("hmac-key-file", "",
+		"file with the HMAC key shared with the operator. If set, unsigned, stale and replayed requests are rejected")
+	var maxSkew = flag.Duration("max-skew", DefaultMaxSkew, "maximum age of a signed request")
+	var tlsCertFile = flag.String("tls-cert-file", "", "serve HTTPS with this certificate")
+	var tlsKeyFile = flag.String("tls-key-file", "", "private key of the certificate in tls-cert-file")
+	var clientCAFile = flag.String("client-ca-file", "",
+		"require clients to present a certificate signed by this CA (mTLS), needs tls-cert-file")
+	flag.Parse()
 
 	ctrl.SetLogger(zap.New())
 	log := log.FromContext(context.Background())
-	http.HandleFunc("/ingest", ingestHandler)
+
+	var v *verifier = nil
+	if *hmacKeyFile != "" {
+		key, err := readHMACKey(*hmacKeyFile)
+		if err != nil {
+			log.Error(err, "cannot read HMAC key")
+			os.Exit(1)
+		}
+		v = &verifier{
+			key:     key,
+			maxSkew: *maxSkew,
+			nonces:  map[string]time.Time{},
+		}
+		log.Info("Signature verification enabled")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ingest", ingestHandler(v))
+	server := &http.Server{
+		Addr:    ":" + *portFlag,
+		Handler: mux,
+	}
+
+	if *clientCAFile != "" {
+		if *tlsCertFile == "" {
+			log.Error(fmt.Errorf("client-ca-file needs tls-cert-file"), "invalid flags")
+			os.Exit(1)
+		}
+		caBundle, err := os.ReadFile(*clientCAFile)
+		if err != nil {
+			log.Error(err, "cannot read client CA")
+			os.Exit(1)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			log.Error(fmt.Errorf("no certificate found in %s", *clientCAFile), "cannot parse client CA")
+			os.Exit(1)
+		}
+		server.TLSConfig = &tls.Config{
+			ClientCAs:  pool,
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
 
 	log.Info("Server listening", "port", *portFlag)
-	
-	if err := http.ListenAndServe(":"+*portFlag, nil); err != nil {
+
+	var err error
+	if *tlsCertFile != "" {
+		err = server.ListenAndServeTLS(*tlsCertFile, *tlsKeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil {
 		log.Error(err, "server error")
 	}
-	
+
 	return
 }
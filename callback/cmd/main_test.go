@@ -0,0 +1,209 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testBody = `{"kive-policy-name":"p"}`
+)
+
+// Signature of the operator, see SignAlert in the sink package
+func sign(key string, timestamp string, nonce string, body string) string {
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp + "." + nonce + "." + body))
+
+	return SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newVerifier(key string) *verifier {
+	return &verifier{
+		key:     []byte(key),
+		maxSkew: DefaultMaxSkew,
+		nonces:  map[string]time.Time{},
+	}
+}
+
+func TestVerify(t *testing.T) {
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-2*DefaultMaxSkew).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(2*DefaultMaxSkew).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		signature string
+		body      string
+		wantErr   string
+	}{
+		{
+			name:      "valid",
+			timestamp: now,
+			nonce:     "nonce-valid",
+			signature: sign("secret", now, "nonce-valid", testBody),
+			body:      testBody,
+		},
+		{
+			name:      "tampered body",
+			timestamp: now,
+			nonce:     "nonce-tampered",
+			signature: sign("secret", now, "nonce-tampered", testBody),
+			body:      `{"kive-policy-name":"q"}`,
+			wantErr:   "invalid signature",
+		},
+		{
+			name:      "wrong key",
+			timestamp: now,
+			nonce:     "nonce-key",
+			signature: sign("not-the-secret", now, "nonce-key", testBody),
+			body:      testBody,
+			wantErr:   "invalid signature",
+		},
+		{
+			name:      "tampered timestamp",
+			timestamp: now,
+			nonce:     "nonce-timestamp",
+			signature: sign("secret", stale, "nonce-timestamp", testBody),
+			body:      testBody,
+			wantErr:   "invalid signature",
+		},
+		{
+			name:      "stale timestamp",
+			timestamp: stale,
+			nonce:     "nonce-stale",
+			signature: sign("secret", stale, "nonce-stale", testBody),
+			body:      testBody,
+			wantErr:   "stale request",
+		},
+		{
+			name:      "future timestamp",
+			timestamp: future,
+			nonce:     "nonce-future",
+			signature: sign("secret", future, "nonce-future", testBody),
+			body:      testBody,
+			wantErr:   "stale request",
+		},
+		{
+			name:      "invalid timestamp",
+			timestamp: "yesterday",
+			nonce:     "nonce-invalid",
+			signature: sign("secret", "yesterday", "nonce-invalid", testBody),
+			body:      testBody,
+			wantErr:   "invalid timestamp",
+		},
+		{
+			name:      "unsigned",
+			timestamp: now,
+			nonce:     "nonce-unsigned",
+			body:      testBody,
+			wantErr:   "unsigned request",
+		},
+	}
+
+	verifier := newVerifier("secret")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			req, err := http.NewRequest(http.MethodPost, "http://callback", strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set(TimestampHeader, test.timestamp)
+			req.Header.Set(NonceHeader, test.nonce)
+			req.Header.Set(SignatureHeader, test.signature)
+
+			err = verifier.verify(req, []byte(test.body))
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("verify: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("verify = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyReplayedNonce(t *testing.T) {
+
+	verifier := newVerifier("secret")
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	for i, wantErr := range []bool{false, true} {
+
+		req, err := http.NewRequest(http.MethodPost, "http://callback", strings.NewReader(testBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(NonceHeader, "nonce")
+		req.Header.Set(SignatureHeader, sign("secret", timestamp, "nonce", testBody))
+
+		err = verifier.verify(req, []byte(testBody))
+		if (err != nil) != wantErr {
+			t.Errorf("request %d: verify = %v, want error %t", i, err, wantErr)
+		}
+	}
+}
+
+func TestReadHMACKey(t *testing.T) {
+
+	// Like the files of kubectl create secret --from-file
+	path := filepath.Join(t.TempDir(), "hmac-key")
+	if err := os.WriteFile(path, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := readHMACKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := newVerifier(string(key))
+
+	// Signed by the operator with the trimmed key
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, "http://callback", strings.NewReader(testBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, "nonce")
+	req.Header.Set(SignatureHeader, sign("secret", timestamp, "nonce", testBody))
+
+	if err := verifier.verify(req, []byte(testBody)); err != nil {
+		t.Error(err)
+	}
+
+	if err := os.WriteFile(path, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readHMACKey(path); err == nil {
+		t.Error("readHMACKey accepted an empty key")
+	}
+}
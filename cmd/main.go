@@ -16,8 +16,11 @@ import (
 	"context"
 	"crypto/tls"
 	"flag"
+	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -29,6 +32,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -39,6 +43,8 @@ import (
 	kive "github.com/San7o/kivebpf/internal/controller"
 	kivecontainer "github.com/San7o/kivebpf/internal/controller/container"
 	kivebpf "github.com/San7o/kivebpf/internal/controller/ebpf"
+	kiveevents "github.com/San7o/kivebpf/internal/controller/events"
+	kivesink "github.com/San7o/kivebpf/internal/controller/sink"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -61,6 +67,23 @@ func main() {
 	var kivePodProbeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var webhookMaxRetries int
+	var webhookInitialBackoff time.Duration
+	var webhookMaxBackoff time.Duration
+	var deadLetterDir string
+	var fileSinkRoot string
+	var deadLetterMaxEntries int
+	var deadLetterReplayInterval time.Duration
+	var recordGCInterval time.Duration
+	var pipelineQueueSize int
+	var pipelineOverflow string
+	var pipelineEnrichWorkers int
+	var pipelineDeliveryWorkers int
+	var eventBurst int
+	var eventInterval time.Duration
+	var lostEventsInterval time.Duration
+	var rescanInterval time.Duration
+	var denyDryRun bool
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -71,6 +94,59 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.IntVar(&webhookMaxRetries, "webhook-sink-max-retries", kivesink.DefaultWebhookMaxRetries,
+		"How many times a failed delivery to a webhook sink is retried before the alert is spooled.")
+	flag.DurationVar(&webhookInitialBackoff, "webhook-sink-initial-backoff", kivesink.DefaultWebhookInitialBackoff,
+		"Wait time before the first retry of a webhook delivery, doubled at each retry.")
+	flag.DurationVar(&webhookMaxBackoff, "webhook-sink-max-backoff", kivesink.DefaultWebhookMaxBackoff,
+		"Maximum wait time between two retries of a webhook delivery.")
+	flag.StringVar(&deadLetterDir, "dead-letter-dir", "/var/lib/kivebpf/dead-letter",
+		"Directory where undelivered webhook alerts are spooled. Leave empty to drop them instead.")
+	flag.StringVar(&fileSinkRoot, "file-sink-root", kivesink.DefaultFileSinkRoot,
+		"Directory of the files of the file sinks, the paths outside of It are refused.")
+	flag.IntVar(&deadLetterMaxEntries, "dead-letter-max-entries", 10000,
+		"Maximum number of spooled alerts, the oldest ones are dropped when It is reached.")
+	flag.DurationVar(&recordGCInterval, "record-gc-interval", kivesink.DefaultRecordGCInterval,
+		"Interval between the deletions of the expired KiveAlertRecords and of those over the maxRecords of their sinks.")
+	flag.DurationVar(&deadLetterReplayInterval, "dead-letter-replay-interval", 30*time.Second,
+		"How often the delivery of spooled alerts is retried.")
+	flag.IntVar(&pipelineQueueSize, "alert-queue-size", kive.DefaultPipelineQueueSize,
+		"Capacity of each queue of the alert pipeline.")
+	flag.StringVar(&pipelineOverflow, "alert-queue-overflow", kive.OverflowBlock,
+		"What to do with a new eBPF event when the events queue is full: block, drop-newest or drop-oldest.")
+	flag.IntVar(&pipelineEnrichWorkers, "alert-enrich-workers", kive.DefaultPipelineEnrichWorkers,
+		"Number of workers generating the alerts from the eBPF events.")
+	flag.IntVar(&pipelineDeliveryWorkers, "alert-delivery-workers", kive.DefaultPipelineDeliveryWorkers,
+		"Number of workers sending the alerts to their sinks.")
+	flag.IntVar(&eventBurst, "event-burst", kiveevents.DefaultBurst,
+		"Number of Kubernetes Events recorded at once for the same object and reason.")
+	flag.DurationVar(&eventInterval, "event-interval", kiveevents.DefaultInterval,
+		"Interval between the Kubernetes Events recorded for the same object and reason once the burst is used.")
+	flag.DurationVar(&lostEventsInterval, "lost-events-interval", kive.DefaultLostEventsInterval,
+		"How often the eBPF events lost because the ring buffer was full are counted and reported.")
+	flag.DurationVar(&rescanInterval, "rescan-interval", controller.DefaultRescanInterval,
+		"How often the paths of the traps are resolved again, to trap the new files and to re-arm the replaced ones. "+
+			"0 disables the rescan, the paths are then resolved again only when the KivePolicies or the pods change.")
+	flag.DurationVar(&kivebpf.RateLimitInterval, "ebpf-rate-limit-interval", 0,
+		"Minimum interval between two alerts of the same process on the same file. The accesses in between "+
+			"are counted by the eBPF program and reported in the next alert. Zero disables the rate limit.")
+	flag.BoolVar(&denyDryRun, "deny-dry-run", false,
+		"Do not deny the accesses to the traps with the deny action, only mark their alerts with would-block.")
+	flag.Func("container-runtime", "Runtime of the containers whose id starts with <name>://, as "+
+		"<name>=<kind>:<socket> where kind is containerd, cri-o or cri, for example "+
+		"containerd=cri:/run/containerd/containerd.sock. Can be repeated.", kivecontainer.ConfigureRuntime)
+	flag.StringVar(&kivecontainer.ContainerdNamespace, "containerd-namespace", kivecontainer.DefaultContainerdNamespace,
+		"Namespace of the containers in the containerd runtimes.")
+	flag.Func("ebpf-backend", "Backend of the eBPF program that traces the accesses, one of auto, lsm, fentry "+
+		"or kprobe. With auto, the first backend supported by the kernel is used in this order.",
+		func(value string) error {
+			backend := kivebpf.Backend(value)
+			if backend != kivebpf.BackendAuto && !slices.Contains(kivebpf.Backends, backend) {
+				return fmt.Errorf("unknown backend %q", value)
+			}
+			kivebpf.RequestedBackend = backend
+			return nil
+		})
 	opts := zap.Options{
 		Development: true,
 	}
@@ -87,6 +163,47 @@ func main() {
 	kive.KernelID = string(kernelIDBytes)
 	kive.KernelID = strings.TrimSpace(kive.KernelID)
 
+	// Set by the downward API, the hostname is used when running
+	// outside of the cluster
+	kive.NodeName = os.Getenv("NODE_NAME")
+	if kive.NodeName == "" {
+		kive.NodeName, err = os.Hostname()
+		if err != nil {
+			setupLog.Error(err, "Cannot read the hostname, set NODE_NAME")
+			os.Exit(1)
+		}
+	}
+
+	if !kive.IsOverflowPolicySupported(pipelineOverflow) {
+		setupLog.Error(fmt.Errorf("unknown policy %s", pipelineOverflow), "Invalid --alert-queue-overflow")
+		os.Exit(1)
+	}
+
+	webhookSink := &kivesink.Webhook{
+		MaxRetries:     webhookMaxRetries,
+		InitialBackoff: webhookInitialBackoff,
+		MaxBackoff:     webhookMaxBackoff,
+	}
+	if deadLetterDir != "" {
+		webhookSink.DeadLetters, err = kivesink.NewDeadLetterQueue(deadLetterDir, deadLetterMaxEntries)
+		if err != nil {
+			// Alerts can still be delivered, they are just not spooled
+			setupLog.Error(err, "Cannot create dead letter queue, undelivered alerts will be dropped")
+		}
+	}
+	kivesink.AlertSinks[kivesink.WebhookSinkType] = webhookSink
+
+	fileSink := &kivesink.File{Root: fileSinkRoot}
+	// The policies could otherwise tamper with the spool
+	if deadLetterDir != "" && fileSink.Overlaps(deadLetterDir) {
+		setupLog.Error(fmt.Errorf("%s overlaps with --dead-letter-dir", fileSinkRoot), "Invalid --file-sink-root")
+		os.Exit(1)
+	}
+	kivesink.AlertSinks[kivesink.FileSinkType] = fileSink
+
+	recordSink := &kivesink.Record{}
+	kivesink.AlertSinks[kivesink.RecordSinkType] = recordSink
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerablpe to the HTTP/2 Stream Cancellation and
@@ -138,6 +255,12 @@ func main() {
 	// Managers
 	// Multiple managers are neded for each resource type since they
 	// need to run different leader elections.
+	//
+	// All the managers share the same metrics registry, so the
+	// metrics are served only by the KiveData manager which runs on
+	// every node. The other managers would fail to bind the same
+	// address.
+	noMetricsServerOptions := metricsserver.Options{BindAddress: "0"}
 
 	// KiveData manager
 	kiveDataMgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
@@ -153,12 +276,21 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Alerts are sent by the KiveData manager, which reads the
+	// Secrets used to authenticate them and stores the
+	// KiveAlertRecords. The records are not cached on the nodes
+	webhookSink.Secrets = kiveDataMgr.GetAPIReader()
+	recordSink.Client = kiveDataMgr.GetClient()
+	recordSink.Reader = kiveDataMgr.GetAPIReader()
+
 	// Kive manager
 	kivePolicyMgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
-		Metrics:                metricsServerOptions,
+		Metrics:                noMetricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: kivePolicyProbeAddr,
+		// Only the pods of this node are cached and trapped
+		Cache: kive.NodeCacheOptions(kive.NodeName),
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start kive manager")
@@ -168,7 +300,7 @@ func main() {
 	// Pod manager
 	kivePodMgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
-		Metrics:                metricsServerOptions,
+		Metrics:                noMetricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: kivePodProbeAddr,
 		LeaderElection:         true,
@@ -183,15 +315,32 @@ func main() {
 		Client:         kivePolicyMgr.GetClient(),
 		UncachedClient: kivePolicyMgr.GetAPIReader(),
 		Scheme:         kivePolicyMgr.GetScheme(),
+		Recorder: kiveevents.NewRecorder(kivePolicyMgr.GetEventRecorderFor("kivebpf"),
+			eventBurst, eventInterval),
+		RescanInterval: rescanInterval,
 	}).SetupWithManager(kivePolicyMgr); err != nil {
 		setupLog.Error(err, "unable to create KivePolicy controller", "controller", "KivePolicy")
 		os.Exit(1)
 	}
 
+	kiveDataRecorder := kiveevents.NewRecorder(kiveDataMgr.GetEventRecorderFor("kivebpf"),
+		eventBurst, eventInterval)
 	if err = (&controller.KiveDataReconciler{
 		Client:         kiveDataMgr.GetClient(),
 		UncachedClient: kiveDataMgr.GetAPIReader(),
 		Scheme:         kiveDataMgr.GetScheme(),
+		Output: &controller.AlertPipeline{
+			QueueSize:          pipelineQueueSize,
+			Overflow:           pipelineOverflow,
+			EnrichWorkers:      pipelineEnrichWorkers,
+			DeliveryWorkers:    pipelineDeliveryWorkers,
+			LostEventsInterval: lostEventsInterval,
+			Recorder:           kiveDataRecorder,
+		},
+		Recorder:   kiveDataRecorder,
+		DenyDryRun: denyDryRun,
+		// Shared with the Output
+		Index: kivebpf.NewInodeIndex(),
 	}).SetupWithManager(kiveDataMgr); err != nil {
 		setupLog.Error(err, "unable to create KiveData controller", "controller", "KiveData")
 		os.Exit(1)
@@ -205,6 +354,21 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The expired KiveAlertRecords of the cluster are deleted only
+	// by the leader of the Pod manager
+	recordCollector := &kivesink.Record{
+		Client: kivePodMgr.GetClient(),
+		Reader: kivePodMgr.GetAPIReader(),
+	}
+	err = kivePodMgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		recordCollector.CollectGarbage(ctx, recordGCInterval)
+		return nil
+	}))
+	if err != nil {
+		setupLog.Error(err, "unable to add the KiveAlertRecord garbage collector")
+		os.Exit(1)
+	}
+
 	err = ctrl.NewWebhookManagedBy(kivePodMgr).
 		For(&kivev2alpha1.KivePolicy{}).
 		Complete()
@@ -292,6 +456,8 @@ func main() {
 
 	kiveDataMgrCtx := ctrl.SetupSignalHandler()
 
+	go webhookSink.ReplayDeadLetters(kiveDataMgrCtx, deadLetterReplayInterval)
+
 	// Unload the eBPF program when leadership is lost
 	go func() {
 		<-kiveDataMgrCtx.Done() // Wait until leadership is lost
@@ -312,5 +478,8 @@ func main() {
 	if err := kivebpf.UnloadEbpf(context.Background()); err != nil {
 		setupLog.Error(err, "Error unloading eBPF programs")
 	}
+	if err := kivesink.CloseSinks(); err != nil {
+		setupLog.Error(err, "Error closing alert sinks")
+	}
 	return
 }
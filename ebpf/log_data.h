@@ -10,6 +10,24 @@
 #define TASK_COMM_LEN 16
 #endif
 
+/*
+ *  Size of the paths of struct log_data, and of the longest name of
+ *  a directory or file in them with Its NUL. Each name is followed
+ *  by a NUL, from the last one up to the root of the task, which is
+ *  "/". A path that does not fit is left empty.
+ */
+#define PATH_LEN       256
+#define PATH_NAME_LEN  64
+#define PATH_MAX_DEPTH 16 /* names and mountpoints walked, the verifier rejects more */
+
+/*
+ *  Operations on a traced inode
+ */
+#define OP_PERMISSION 0 /* permission check, the mask is MAY_* */
+#define OP_UNLINK     1
+#define OP_RENAME     2
+#define OP_SETATTR    3 /* the mask is ATTR_* of the changed attributes */
+
 struct log_data {
 	pid_t pid;                /* process id */
 	gid_t tgid;               /* thread group id */
@@ -19,6 +37,14 @@ struct log_data {
 	long unsigned int ino;    /* inode number */
 	int mask;                 /* Octal representation of file permissions */
   char comm[TASK_COMM_LEN]; /* name of the executable of the task */
+  __u32 suppressed;         /* accesses suppressed since the last event */
+  __u32 operation;          /* OP_* */
+  __u32 blocked;            /* DENY_* action applied, 0 if none */
+  __u32 ns_pid;             /* process id in Its pid namespace */
+  __u32 ns_tgid;            /* thread group id in Its pid namespace */
+  __u64 start_time;         /* start of the thread group, ns since boot */
+  char exe[PATH_LEN];       /* path of the executable, see PATH_LEN */
+  char cwd[PATH_LEN];       /* current working directory, see PATH_LEN */
 };
 
 #endif // _HIVE_DATA_H_
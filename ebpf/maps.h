@@ -9,12 +9,40 @@
 #include <bpf/bpf_helpers.h>
 
 #define MAP_MAX_ENTRIES 1024
+#define RATE_LIMIT_MAX_ENTRIES 10240
+#define ALLOWLIST_MAX_ENTRIES 4096
+
+/*
+ *  Kinds of the ids in the allowed_ids map
+ */
+#define ALLOW_UID 0
+#define ALLOW_GID 1
+#define ALLOW_PID 2
+
+/*
+ *  Access mask of inode_permission, from include/linux/fs.h
+ */
+#define MAY_EXEC   0x00000001
+#define MAY_WRITE  0x00000002
+#define MAY_READ   0x00000004
+#define MAY_APPEND 0x00000008
+#define MAY_ACCESS 0x00000010
+#define MAY_OPEN   0x00000020
+
+/*
+ *  Value of the traced_inodes map that alerts on any access
+ */
+#define ACCESS_ALL 0xff
 
 struct map_key {
   long unsigned int inode;
   dev_t dev;
 };
 
+/*
+ *  The value is the MAY_* bits of the accesses to be alerted, or
+ *  ACCESS_ALL
+ */
 struct {
   __uint(type, BPF_MAP_TYPE_HASH);
   __type(key, struct map_key);
@@ -22,6 +50,84 @@ struct {
   __uint(max_entries, MAP_MAX_ENTRIES);
 } traced_inodes SEC(".maps"); 
 
+/*
+ *  Action of the traced inodes whose accesses are denied, only
+ *  enforced by the LSM program
+ */
+#define DENY_ENFORCE 1 /* deny the access */
+#define DENY_DRY_RUN 2 /* only report that the access would be denied */
+
+struct {
+  __uint(type, BPF_MAP_TYPE_HASH);
+  __type(key, struct map_key);
+  __type(value, u8);
+  __uint(max_entries, MAP_MAX_ENTRIES);
+} denied_inodes SEC(".maps");
+
+/*
+ *  Processes whose accesses to a traced file are ignored, by name of
+ *  the executable or by id
+ */
+struct allow_comm_key {
+  long unsigned int inode;
+  dev_t dev;
+  char comm[TASK_COMM_LEN];
+};
+
+struct allow_id_key {
+  long unsigned int inode;
+  dev_t dev;
+  __u32 kind; /* ALLOW_* */
+  __u32 id;
+};
+
+struct {
+  __uint(type, BPF_MAP_TYPE_HASH);
+  __type(key, struct allow_comm_key);
+  __type(value, u8);
+  __uint(max_entries, ALLOWLIST_MAX_ENTRIES);
+} allowed_comms SEC(".maps");
+
+struct {
+  __uint(type, BPF_MAP_TYPE_HASH);
+  __type(key, struct allow_id_key);
+  __type(value, u8);
+  __uint(max_entries, ALLOWLIST_MAX_ENTRIES);
+} allowed_ids SEC(".maps");
+
+/*
+ *  Accesses of a process to a traced file are rate limited
+ *  together
+ */
+struct rate_limit_key {
+  long unsigned int inode;
+  dev_t dev;
+  __u32 tgid;
+};
+
+struct rate_limit_value {
+  __u64 last_ns;    /* time of the last event sent */
+  __u32 suppressed; /* accesses suppressed since then */
+};
+
+struct {
+  __uint(type, BPF_MAP_TYPE_LRU_HASH);
+  __type(key, struct rate_limit_key);
+  __type(value, struct rate_limit_value);
+  __uint(max_entries, RATE_LIMIT_MAX_ENTRIES);
+} rate_limited SEC(".maps");
+
+/*
+ *  Number of events that could not be sent because the ring buffer
+ *  was full, read and summed by user space
+ */
+struct {
+  __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+  __type(key, __u32);
+  __type(value, __u64);
+  __uint(max_entries, 1);
+} lost_events SEC(".maps");
+
 struct {
 	__uint(type, BPF_MAP_TYPE_RINGBUF);
 	__uint(max_entries, 1 << 24);
@@ -10,81 +10,454 @@
 #include <bpf/bpf_tracing.h>
 #include <bpf/bpf_core_read.h>
 
+#ifndef EPERM
+#define EPERM 1
+#endif
+
+/*
+ *  Minimum time between two events of the same process on the same
+ *  file, the accesses in between are only counted. Set from user
+ *  space before loading, 0 disables the rate limit.
+ */
+volatile const __u64 rate_limit_ns = 0;
+
 /*
- *  Fill and send struct log_data to the ring buffer.
+ *  Returns 0 if the event should be suppressed. Otherwise, sets
+ *  suppressed to the number of accesses suppressed since the last
+ *  event of this process on this file.
+ */
+static __always_inline int
+rate_limit(long unsigned int inode, dev_t dev, __u32 tgid, __u32 *suppressed)
+{
+  if (!rate_limit_ns)
+    return 1;
+
+  struct rate_limit_key key = {};
+  key.inode = inode;
+  key.dev   = dev;
+  key.tgid  = tgid;
+
+  __u64 now = bpf_ktime_get_ns();
+  struct rate_limit_value *value = bpf_map_lookup_elem(&rate_limited, &key);
+  if (value)
+  {
+    if (now - value->last_ns < rate_limit_ns)
+    {
+      __sync_fetch_and_add(&value->suppressed, 1);
+      return 0;
+    }
+    *suppressed = value->suppressed;
+    value->suppressed = 0;
+    value->last_ns = now;
+    return 1;
+  }
+
+  struct rate_limit_value new_value = {};
+  new_value.last_ns = now;
+  bpf_map_update_elem(&rate_limited, &key, &new_value, BPF_ANY);
+  return 1;
+}
+
+/*
+ *  Whether an access with mask should be alerted according to the
+ *  filter of Its traced inode.
+ */
+static __always_inline int
+access_matches(int mask, __u8 filter)
+{
+  if (filter == ACCESS_ALL)
+    return 1;
+
+  // access(2) checks are alerted only if requested
+  if ((mask & MAY_ACCESS) && !(filter & MAY_ACCESS))
+    return 0;
+
+  return (mask & filter) != 0;
+}
+
+/*
+ *  Number of pid in the pid namespace where It was allocated, which
+ *  is the one of the task, the pid namespace of Its container.
+ */
+static __always_inline __u32
+pid_nr_ns(struct pid *pid)
+{
+  unsigned int level = BPF_CORE_READ(pid, level);
+
+  struct upid upid = {};
+  bpf_core_read(&upid, sizeof(upid), &pid->numbers[level]);
+  return upid.nr;
+}
+
+/*
+ *  Thread group id of the current task in Its own pid namespace.
+ */
+static __always_inline __u32
+ns_tgid(void)
+{
+  struct task_struct *task = (struct task_struct*) bpf_get_current_task();
+  return pid_nr_ns(BPF_CORE_READ(task, group_leader, thread_pid));
+}
+
+/*
+ *  Process id of the current task in Its own pid namespace.
+ */
+static __always_inline __u32
+ns_pid(void)
+{
+  struct task_struct *task = (struct task_struct*) bpf_get_current_task();
+  return pid_nr_ns(BPF_CORE_READ(task, thread_pid));
+}
+
+// The field was named real_start_time before linux 5.5
+struct task_struct___pre_5_5 {
+  u64 real_start_time;
+} __attribute__((preserve_access_index));
+
+/*
+ *  Start of the thread group of the current task in nanoseconds
+ *  since boot, the starttime of /proc/<tgid>/stat in clock ticks.
+ *  User space compares them to tell Its process apart from another
+ *  one with the same pid in a different pid namespace.
+ */
+static __always_inline __u64
+start_time(void)
+{
+  struct task_struct *task = (struct task_struct*) bpf_get_current_task();
+  struct task_struct *leader = BPF_CORE_READ(task, group_leader);
+
+  if (bpf_core_field_exists(leader->start_boottime))
+    return BPF_CORE_READ(leader, start_boottime);
+
+  return BPF_CORE_READ((struct task_struct___pre_5_5*) leader, real_start_time);
+}
+
+/*
+ *  Write the path of dentry in the mount vfsmnt to buf, in the format
+ *  of PATH_LEN, up to the root of the current task. At most
+ *  PATH_MAX_DEPTH names and mounts are walked. bpf_d_path cannot be
+ *  called from the probed functions, so the dentries are walked
+ *  instead.
  */
 static __always_inline void
-kprobe_output(long unsigned int inode, dev_t dev, int mask)
+read_path(struct dentry *dentry, struct vfsmount *vfsmnt, char *buf)
 {
-  struct log_data data = {};
-  
-  __u64 pid_tgid = bpf_get_current_pid_tgid();
+  struct task_struct *task = (struct task_struct*) bpf_get_current_task();
+  struct dentry *root = BPF_CORE_READ(task, fs, root.dentry);
+  struct vfsmount *root_mnt = BPF_CORE_READ(task, fs, root.mnt);
+  struct mount *mnt = container_of(vfsmnt, struct mount, mnt);
+  __u32 off = 0;
+
+  if (!dentry || !vfsmnt)
+    goto fail;
+
+  for (int i = 0; i < PATH_MAX_DEPTH; i++)
+  {
+    if (dentry == root && vfsmnt == root_mnt)
+      goto done;
+
+    if (dentry == BPF_CORE_READ(vfsmnt, mnt_root))
+    {
+      struct mount *parent = BPF_CORE_READ(mnt, mnt_parent);
+      // The root of the mount namespace, outside of the root of the task
+      if (parent == mnt)
+        goto done;
+      dentry = BPF_CORE_READ(mnt, mnt_mountpoint);
+      mnt = parent;
+      vfsmnt = __builtin_preserve_access_index(&parent->mnt);
+      continue;
+    }
+
+    struct qstr name = BPF_CORE_READ(dentry, d_name);
+    if (name.len >= PATH_NAME_LEN || off > PATH_LEN - PATH_NAME_LEN)
+      goto fail;
+    long ret = bpf_probe_read_kernel_str(buf + off, PATH_NAME_LEN, name.name);
+    if (ret <= 0)
+      goto fail;
+    off += ret;
+
+    dentry = BPF_CORE_READ(dentry, d_parent);
+  }
+
+fail:
+  buf[0] = 0;
+  return;
+
+done:
+  // buf is zeroed, the NUL after the root is already there
+  if (off > PATH_LEN - 2)
+    goto fail;
+  buf[off] = '/';
+}
+
+/*
+ *  Whether the current task is in the allowlist of the traced inode,
+ *  so that Its accesses are ignored.
+ */
+static __always_inline int
+is_allowed(long unsigned int inode, dev_t dev)
+{
+  struct allow_comm_key comm_key = {};
+  comm_key.inode = inode;
+  comm_key.dev   = dev;
+  bpf_get_current_comm(comm_key.comm, TASK_COMM_LEN);
+  if (bpf_map_lookup_elem(&allowed_comms, &comm_key))
+    return 1;
+
   __u64 uid_gid = bpf_get_current_uid_gid();
+  struct allow_id_key id_key = {};
+  id_key.inode = inode;
+  id_key.dev   = dev;
+
+  id_key.kind = ALLOW_UID;
+  id_key.id   = (__u32) uid_gid;
+  if (bpf_map_lookup_elem(&allowed_ids, &id_key))
+    return 1;
+
+  id_key.kind = ALLOW_GID;
+  id_key.id   = uid_gid >> 32;
+  if (bpf_map_lookup_elem(&allowed_ids, &id_key))
+    return 1;
+
+  id_key.kind = ALLOW_PID;
+  id_key.id   = ns_tgid();
+  if (bpf_map_lookup_elem(&allowed_ids, &id_key))
+    return 1;
 
-  data.tgid = pid_tgid >> 32;
-  data.pid = (gid_t) pid_tgid;
-  data.gid = uid_gid >> 32;
-  data.uid = (gid_t) uid_gid;
-  data.ino = inode;
-  data.dev = dev;
-  data.mask = mask;
-  bpf_get_current_comm(data.comm, TASK_COMM_LEN);
-		
-  bpf_ringbuf_output(&rb, &data, sizeof(struct log_data), 0);
+  return 0;
 }
 
 /*
- *  Probed function:
- *  int inode_permission(struct mnt_idmap *idmap,
- *	               	     struct inode *inode, int mask)
- *  Description: Check if accessing an inode is allowed
+ *  Fill and send struct log_data to the ring buffer. With Its paths
+ *  It does not fit in the stack, so It is written in place.
  */
-// kprobe_inode_permission_old should be loaded on kernels < 5.12
-SEC("kprobe/inode_permission")
-int kprobe_inode_permission_old(struct pt_regs *ctx)
+static __always_inline void
+kprobe_output(long unsigned int inode, dev_t dev, int mask, __u32 operation,
+              __u32 blocked)
 {
-  struct inode *inode = (struct inode*) PT_REGS_PARM1(ctx);
-  int mask = (int) PT_REGS_PARM2(ctx);
+  __u32 suppressed = 0;
+  __u64 pid_tgid = bpf_get_current_pid_tgid();
+  __u64 uid_gid = bpf_get_current_uid_gid();
+
+  // Tampering with a traced file is always sent
+  if (operation == OP_PERMISSION &&
+      !rate_limit(inode, dev, pid_tgid >> 32, &suppressed))
+    return;
+
+  struct log_data *data = bpf_ringbuf_reserve(&rb, sizeof(struct log_data), 0);
+  if (!data)
+  {
+    __u32 zero = 0;
+    __u64 *lost = bpf_map_lookup_elem(&lost_events, &zero);
+    if (lost)
+      (*lost)++;
+    return;
+  }
+  __builtin_memset(data, 0, sizeof(struct log_data));
+
+  data->tgid = pid_tgid >> 32;
+  data->pid = (gid_t) pid_tgid;
+  data->gid = uid_gid >> 32;
+  data->uid = (gid_t) uid_gid;
+  data->ino = inode;
+  data->dev = dev;
+  data->mask = mask;
+  data->suppressed = suppressed;
+  data->operation = operation;
+  data->blocked = blocked;
+  data->ns_pid = ns_pid();
+  data->ns_tgid = ns_tgid();
+  data->start_time = start_time();
+  bpf_get_current_comm(data->comm, TASK_COMM_LEN);
+
+  struct task_struct *task = (struct task_struct*) bpf_get_current_task();
+  read_path(BPF_CORE_READ(task, mm, exe_file, f_path.dentry),
+            BPF_CORE_READ(task, mm, exe_file, f_path.mnt), data->exe);
+  read_path(BPF_CORE_READ(task, fs, pwd.dentry),
+            BPF_CORE_READ(task, fs, pwd.mnt), data->cwd);
 
+  bpf_ringbuf_submit(data, 0);
+}
+
+/*
+ *  Send an event if inode is traced and the access with mask matches
+ *  Its filter, used by every backend of the permission checks.
+ *  Returns -EPERM if the inode is denied and the backend can_deny,
+ *  the other backends only report the accesses that would be denied
+ *  in dry-run mode.
+ */
+static __always_inline int
+trace_inode(struct inode *inode, int mask, int can_deny)
+{
   if (!inode)
     return 0;
-    
+
   long unsigned int ino = BPF_CORE_READ(inode, i_ino);
   dev_t dev = BPF_CORE_READ(inode, i_sb, s_dev);
   struct map_key key = {};
   key.inode = ino;
   key.dev   = dev;
 
-  if (bpf_map_lookup_elem(&traced_inodes, &key))
-  {
-    kprobe_output(ino, dev, mask);
+  __u8 *filter = bpf_map_lookup_elem(&traced_inodes, &key);
+  if (!filter || !access_matches(mask, *filter) || is_allowed(ino, dev))
     return 0;
-  }
-    
+
+  __u8 action = 0;
+  __u8 *deny = bpf_map_lookup_elem(&denied_inodes, &key);
+  if (deny && (can_deny || *deny == DENY_DRY_RUN))
+    action = *deny;
+
+  kprobe_output(ino, dev, mask, OP_PERMISSION, action);
+  return action == DENY_ENFORCE ? -EPERM : 0;
+}
+
+/*
+ *  LSM hook:
+ *  int inode_permission(struct inode *inode, int mask)
+ *  Description: Check permission before accessing an inode. The
+ *  signature of the hook is stable across kernel versions. The
+ *  accesses to the denied inodes fail with -EPERM, ret is the
+ *  decision of the previous LSM programs.
+ */
+SEC("lsm/inode_permission")
+int BPF_PROG(lsm_inode_permission, struct inode *inode, int mask, int ret)
+{
+  if (ret)
+    return ret;
+
+  return trace_inode(inode, mask, 1);
+}
+
+/*
+ *  Traced function:
+ *  int security_inode_permission(struct inode *inode, int mask)
+ *  Description: Call the LSM hooks of inode_permission, used when
+ *  BPF LSM is not enabled
+ */
+SEC("fentry/security_inode_permission")
+int BPF_PROG(fentry_inode_permission, struct inode *inode, int mask)
+{
+  trace_inode(inode, mask, 0);
+  return 0;
+}
+
+/*
+ *  Probed function:
+ *  int inode_permission(struct mnt_idmap *idmap,
+ *	               	     struct inode *inode, int mask)
+ *  Description: Check if accessing an inode is allowed
+ */
+// kprobe_inode_permission_old is loaded when inode_permission has
+// no idmap argument, before linux 5.12
+SEC("kprobe/inode_permission")
+int kprobe_inode_permission_old(struct pt_regs *ctx)
+{
+  struct inode *inode = (struct inode*) PT_REGS_PARM1(ctx);
+  int mask = (int) PT_REGS_PARM2(ctx);
+
+  trace_inode(inode, mask, 0);
   return 0;
 }
 
-// kprobe_inode_permission_new should be loaded on kernels >= 5.12
+// kprobe_inode_permission_new is loaded when inode_permission has
+// an idmap argument
 SEC("kprobe/inode_permission")
 int kprobe_inode_permission_new(struct pt_regs *ctx)
 {
   struct inode *inode = (struct inode*) PT_REGS_PARM2(ctx);
   int mask = (int) PT_REGS_PARM3(ctx);
- 
+
+  trace_inode(inode, mask, 0);
+  return 0;
+}
+
+/*
+ *  Send an event if the inode of dentry is traced, used by the
+ *  probes of the operations that tamper with a file.
+ */
+static __always_inline void
+trace_dentry(struct dentry *dentry, int mask, __u32 operation)
+{
+  if (!dentry)
+    return;
+
+  struct inode *inode = BPF_CORE_READ(dentry, d_inode);
   if (!inode)
-    return 0;
-    
+    return;
+
   long unsigned int ino = BPF_CORE_READ(inode, i_ino);
   dev_t dev = BPF_CORE_READ(inode, i_sb, s_dev);
   struct map_key key = {};
   key.inode = ino;
   key.dev   = dev;
 
-  if (bpf_map_lookup_elem(&traced_inodes, &key))
-  {
-    kprobe_output(ino, dev, mask);
-    return 0;
-  }
-    
+  if (bpf_map_lookup_elem(&traced_inodes, &key) && !is_allowed(ino, dev))
+    kprobe_output(ino, dev, mask, operation, 0);
+}
+
+/*
+ *  Probed function:
+ *  int security_inode_unlink(struct inode *dir, struct dentry *dentry)
+ *  Description: Check permission to remove a hard link to a file
+ */
+SEC("kprobe/security_inode_unlink")
+int kprobe_inode_unlink(struct pt_regs *ctx)
+{
+  struct dentry *dentry = (struct dentry*) PT_REGS_PARM2(ctx);
+
+  trace_dentry(dentry, 0, OP_UNLINK);
+  return 0;
+}
+
+/*
+ *  Probed function:
+ *  int security_inode_rename(struct inode *old_dir,
+ *                            struct dentry *old_dentry,
+ *                            struct inode *new_dir,
+ *                            struct dentry *new_dentry,
+ *                            unsigned int flags)
+ *  Description: Check permission to rename a file, the file at the
+ *  new path, if any, is replaced
+ */
+SEC("kprobe/security_inode_rename")
+int kprobe_inode_rename(struct pt_regs *ctx)
+{
+  struct dentry *old_dentry = (struct dentry*) PT_REGS_PARM2(ctx);
+  struct dentry *new_dentry = (struct dentry*) PT_REGS_PARM4(ctx);
+
+  trace_dentry(old_dentry, 0, OP_RENAME);
+  trace_dentry(new_dentry, 0, OP_RENAME);
+  return 0;
+}
+
+/*
+ *  Probed function:
+ *  int security_inode_setattr(struct mnt_idmap *idmap,
+ *                             struct dentry *dentry,
+ *                             struct iattr *attr)
+ *  Description: Check permission to change the attributes of a file,
+ *  like Its mode or owner
+ */
+// kprobe_inode_setattr_old should be loaded on kernels where the
+// function has no idmap argument
+SEC("kprobe/security_inode_setattr")
+int kprobe_inode_setattr_old(struct pt_regs *ctx)
+{
+  struct dentry *dentry = (struct dentry*) PT_REGS_PARM1(ctx);
+  struct iattr *attr = (struct iattr*) PT_REGS_PARM2(ctx);
+
+  trace_dentry(dentry, BPF_CORE_READ(attr, ia_valid), OP_SETATTR);
+  return 0;
+}
+
+// kprobe_inode_setattr_new should be loaded on kernels where the
+// function has an idmap argument
+SEC("kprobe/security_inode_setattr")
+int kprobe_inode_setattr_new(struct pt_regs *ctx)
+{
+  struct dentry *dentry = (struct dentry*) PT_REGS_PARM2(ctx);
+  struct iattr *attr = (struct iattr*) PT_REGS_PARM3(ctx);
+
+  trace_dentry(dentry, BPF_CORE_READ(attr, ia_valid), OP_SETATTR);
   return 0;
 }
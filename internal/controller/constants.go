@@ -9,4 +9,7 @@ const (
 
 	// Label used to store the trap identifier
 	TrapIDLabel = "trap-id"
+
+	// Characters of the trap identifier in the name of a KiveData
+	KiveDataNameTrapIDLength = 10
 )
@@ -14,21 +14,36 @@ package container
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 
 	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
 )
 
-const (
+var (
 	// The mountpoint inside the operator's container of the node's
 	// procfs
 	ProcMountpoint = "/host/proc"
+	// Namespace of the containers of the containerd runtimes that do
+	// not set one
+	ContainerdNamespace = DefaultContainerdNamespace
+)
+
+const (
 	// If the node is a container (for example, this happens with
 	// clusters created with Kind), the actual host's procfs is assumed
 	// to be mounted here
 	RealHostProcMountpoint = "/host/real/proc"
+
+	// Levels below the directory of a recursive trap that are
+	// trapped if the trap does not specify It
+	DefaultMaxDepth = 8
+	// Maximum number of files trapped by a recursive trap or a
+	// pattern in a container, which is also the size of the eBPF map
+	MaxTreeFiles = 1024
 )
 
 type ContainerName = string
@@ -46,6 +61,21 @@ type ContainerData struct {
 	ShouldRequeue bool
 	// False if an inode was not found, used for improved error messages
 	IsFound bool
+	// True if the file did not exist and has been created
+	Created bool
+	// The files trapped in the container: the file at the trapped
+	// path, the files matching It if It is a pattern, and the files
+	// below the directories if the trap is recursive
+	Files []ContainerFile
+}
+
+// A file trapped in a container
+type ContainerFile struct {
+	// Path of the file in the container
+	Path  string
+	Ino   Ino
+	DevID Dev
+	isDir bool
 }
 
 type Runtime interface {
@@ -57,15 +87,80 @@ type Runtime interface {
 
 var (
 	ContainerRuntimes map[string]Runtime = make(map[string]Runtime)
+	// Returned by a Runtime when the container is not managed by It,
+	// for example because It runs on another node
+	ErrContainerNotFound = errors.New("container not found")
+	// Returned when a recursive trap or a pattern matches more than
+	// MaxTreeFiles files
+	ErrTreeTooLarge = errors.New("too many files below the directory")
+	// Returned when the path of a trap would resolve outside of the
+	// root filesystem of the container
+	ErrPathOutsideRoot = errors.New("path outside of the root of the container")
+	// Returned when a regular expression does not start with the
+	// directory that contains Its matches
+	ErrNoLiteralPrefix = errors.New("no literal directory prefix")
+)
+
+const (
+	// Kinds of the Runtimes that can be configured
+	RuntimeKindContainerd = "containerd"
+	RuntimeKindCrio       = "cri-o"
+	RuntimeKindCRI        = "cri"
+
+	// Default socket of cri-dockerd, the CRI of Docker Engine
+	DefaultCriDockerdAddress = "/run/cri-dockerd.sock"
 )
 
 func init() {
 	ContainerRuntimes = map[string]Runtime{
-		"containerd": &Containerd{}, // Currently only containerd is supported
+		"containerd": &Containerd{Address: DefaultContainerdAddress},
+		"cri-o":      &Crio{Address: CrioAddress},
+		"docker":     &CRI{Address: DefaultCriDockerdAddress},
 	}
 }
 
+/*
+ *  Configure the Runtime of the containers whose id starts with
+ *  "<name>://" from a setting "<name>=<kind>:<address>", where kind
+ *  is one of the RuntimeKind* and address is the path of the unix
+ *  socket of the runtime, for example
+ *  "containerd=cri:/run/containerd/containerd.sock". It replaces the
+ *  Runtime previously configured for name.
+ */
+func ConfigureRuntime(setting string) error {
+
+	name, runtime, ok := strings.Cut(setting, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("ConfigureRuntime Error %q is not <name>=<kind>:<address>", setting)
+	}
+	kind, address, ok := strings.Cut(runtime, ":")
+	if !ok || address == "" {
+		return fmt.Errorf("ConfigureRuntime Error %q is not <name>=<kind>:<address>", setting)
+	}
+
+	switch kind {
+	case RuntimeKindContainerd:
+		ContainerRuntimes[name] = &Containerd{Address: address}
+	case RuntimeKindCrio:
+		ContainerRuntimes[name] = &Crio{Address: address}
+	case RuntimeKindCRI:
+		ContainerRuntimes[name] = &CRI{Address: address}
+	default:
+		return fmt.Errorf("ConfigureRuntime Error Unknown kind %q of runtime %s", kind, name)
+	}
+
+	return nil
+}
+
 func GetContainerData(ctx context.Context, containerStatus corev1.ContainerStatus, kiveTrap kivev2alpha1.KiveTrap) (ContainerData, error) {
+	return GetRuntimeContainerData(ctx, ContainerRuntimes, containerStatus, kiveTrap)
+}
+
+/*
+ *  Like GetContainerData, with the Runtime of the container chosen
+ *  among runtimes instead of ContainerRuntimes.
+ */
+func GetRuntimeContainerData(ctx context.Context, runtimes map[string]Runtime, containerStatus corev1.ContainerStatus, kiveTrap kivev2alpha1.KiveTrap) (ContainerData, error) {
 
 	if !containerStatus.Ready {
 		return ContainerData{ShouldRequeue: true}, nil
@@ -75,11 +170,10 @@ func GetContainerData(ctx context.Context, containerStatus corev1.ContainerStatu
 	if err != nil {
 		return ContainerData{}, err
 	}
-	supported := IsContainerRuntimeSupported(runtimeName)
+	runtime, supported := runtimes[runtimeName]
 	if !supported {
 		return ContainerData{}, fmt.Errorf("GetContainerData Error: Container runtime %s is not supported.", runtimeName)
 	}
-	runtime := ContainerRuntimes[runtimeName]
 
 	if !runtime.IsConnected() {
 		if err := runtime.Connect(ctx); err != nil {
@@ -88,13 +182,13 @@ func GetContainerData(ctx context.Context, containerStatus corev1.ContainerStatu
 	}
 
 	containerData, err := runtime.GetContainerData(ctx, containerId, kiveTrap)
-	if err == nil {
-		containerData.ID = containerStatus.ContainerID
-		containerData.Name = containerStatus.Name
-		return containerData, nil
+	containerData.ID = containerStatus.ContainerID
+	containerData.Name = containerStatus.Name
+	if err != nil {
+		return containerData, fmt.Errorf("GetContainerData Error: %w", err)
 	}
 
-	return ContainerData{}, nil
+	return containerData, nil
 }
 
 func CloseConnections() error {
@@ -15,8 +15,6 @@ package container
 import (
 	"context"
 	"fmt"
-	"strconv"
-	"syscall"
 
 	containerd "github.com/containerd/containerd"
 	containerdCio "github.com/containerd/containerd/cio"
@@ -25,13 +23,19 @@ import (
 )
 
 const (
-	containerdAddress = "/run/containerd/containerd.sock"
-	separator         = "/"
-	namespace         = "k8s.io"
+	DefaultContainerdAddress = "/run/containerd/containerd.sock"
+	// Namespace of the containers created by Kubernetes
+	DefaultContainerdNamespace = "k8s.io"
+	separator                  = "/"
 )
 
 type Containerd struct {
-	Client      *containerd.Client
+	Client *containerd.Client
+	// Path of the unix socket of containerd
+	Address string
+	// Namespace of the containers in containerd, ContainerdNamespace
+	// if empty
+	Namespace   string
 	isConnected bool
 }
 
@@ -44,8 +48,12 @@ func (self *Containerd) Connect(ctx context.Context) error {
 			return fmt.Errorf("Containerd Connect Error IsServing(): %w", err)
 		}
 	} else {
+		namespace := self.Namespace
+		if namespace == "" {
+			namespace = ContainerdNamespace
+		}
 		opt := containerd.WithDefaultNamespace(namespace)
-		self.Client, err = containerd.New(containerdAddress, opt)
+		self.Client, err = containerd.New(self.Address, opt)
 		if err != nil {
 			return fmt.Errorf("Containerd Connect Error New connection: %w", err)
 		}
@@ -87,46 +95,9 @@ func (self *Containerd) GetContainerData(ctx context.Context, id string, kiveTra
 				return ContainerData{}, err
 			}
 
-			inode, dev, err := getInodeDev(task.Pid(),
-				kiveTrap.Path, kiveTrap.Create, kiveTrap.Mode)
-			if err != nil {
-				return ContainerData{}, err
-			}
-
-			return ContainerData{
-				Ino:     inode,
-				DevID:   UserDevToKernelDev(dev),
-				IsFound: true,
-			}, nil
+			return ResolveTrap(task.Pid(), kiveTrap)
 		}
 	}
 
-	return ContainerData{}, fmt.Errorf("Containerd GetContainerData Inode not found")
-}
-
-/*
- *  Get the inode of a file, given the path and the pid of the
- *  container where the file lives. Creates the file with mode
- *  permissions if create is set to true.
- */
-func getInodeDev(pid Pid, path string, create bool, mode uint32) (Ino, uint64, error) {
-	pidStr := strconv.FormatUint(uint64(pid), 10)
-	target := ProcMountpoint + separator + pidStr +
-		separator + "root" + separator + path
-	var stat syscall.Stat_t
-
-	if create {
-		fd, err := syscall.Creat(target, mode)
-		if err != nil {
-			return uint64(0), uint64(0), err
-		}
-		syscall.Close(fd)
-	}
-
-	err := syscall.Stat(target, &stat)
-	if err != nil {
-		return uint64(0), uint64(0), err
-	}
-
-	return stat.Ino, stat.Dev, nil
+	return ContainerData{}, fmt.Errorf("Containerd GetContainerData %s: %w", id, ErrContainerNotFound)
 }
@@ -0,0 +1,116 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Test helpers of the container package, which are not built into
+// the operator
+package containertest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+	container "github.com/San7o/kivebpf/internal/controller/container"
+)
+
+// In-memory container.Runtime that knows the containers set with
+// SetContainer, so the reconcilers can be tested without a node.
+// The paths of the traps are not resolved: the Files of a container
+// are returned for every trap, or Its Ino at the path of the trap if
+// It has none.
+type Runtime struct {
+	mutex       sync.Mutex
+	containers  map[container.ContainerID]container.ContainerData
+	isConnected bool
+}
+
+var _ container.Runtime = &Runtime{}
+
+func NewRuntime() *Runtime {
+	return &Runtime{
+		containers: map[container.ContainerID]container.ContainerData{},
+	}
+}
+
+/*
+ *  Set the data returned for the container with id, the id without
+ *  the "<runtime>://" prefix
+ */
+func (self *Runtime) SetContainer(id container.ContainerID, containerData container.ContainerData) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.containers[id] = containerData
+}
+
+/*
+ *  Forget the container with id, as if It ran on another node
+ */
+func (self *Runtime) RemoveContainer(id container.ContainerID) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	delete(self.containers, id)
+}
+
+func (self *Runtime) Connect(ctx context.Context) error {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.isConnected = true
+
+	return nil
+}
+
+func (self *Runtime) Disconnect() error {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.isConnected = false
+
+	return nil
+}
+
+func (self *Runtime) IsConnected() bool {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	return self.isConnected
+}
+
+func (self *Runtime) GetContainerData(ctx context.Context, id container.ContainerID, kiveTrap kivev2alpha1.KiveTrap) (container.ContainerData, error) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	containerData, ok := self.containers[id]
+	if !ok {
+		return container.ContainerData{}, fmt.Errorf("Runtime GetContainerData %s: %w", id, container.ErrContainerNotFound)
+	}
+
+	containerData.Files = append([]container.ContainerFile{}, containerData.Files...)
+	if len(containerData.Files) == 0 && containerData.IsFound {
+		containerData.Files = []container.ContainerFile{{
+			Path:  kiveTrap.Path,
+			Ino:   containerData.Ino,
+			DevID: containerData.DevID,
+		}}
+	}
+
+	return containerData, nil
+}
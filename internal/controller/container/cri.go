@@ -0,0 +1,148 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	criTimeout = 10 * time.Second
+)
+
+// The pid of a container in the verbose information of Its status.
+// containerd, CRI-O and cri-dockerd report It in a JSON object
+type criContainerInfo struct {
+	Pid int `json:"pid"`
+}
+
+// Runtime that speaks the RuntimeService of the Kubernetes CRI over
+// a unix socket, so It works with any runtime implementing the CRI.
+// The pid of a container is read from the verbose information of
+// Its status.
+type CRI struct {
+	// Path of the unix socket of the runtime
+	Address     string
+	conn        *grpc.ClientConn
+	client      runtimeapi.RuntimeServiceClient
+	isConnected bool
+}
+
+func (self *CRI) Connect(ctx context.Context) error {
+
+	if self.conn == nil {
+		conn, err := grpc.NewClient("unix://"+self.Address,
+			grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return fmt.Errorf("CRI Connect Error New client %s: %w", self.Address, err)
+		}
+		self.conn = conn
+		self.client = runtimeapi.NewRuntimeServiceClient(conn)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, criTimeout)
+	defer cancel()
+
+	if _, err := self.client.Version(ctx, &runtimeapi.VersionRequest{}); err != nil {
+		return fmt.Errorf("CRI Connect Error Version %s: %w", self.Address, err)
+	}
+
+	self.isConnected = true
+
+	return nil
+}
+
+func (self *CRI) Disconnect() error {
+
+	if self.conn != nil {
+		if err := self.conn.Close(); err != nil {
+			return fmt.Errorf("CRI Disconnect Error Close: %w", err)
+		}
+		self.conn = nil
+		self.client = nil
+	}
+
+	self.isConnected = false
+
+	return nil
+}
+
+func (self *CRI) IsConnected() bool {
+	return self.isConnected
+}
+
+func (self *CRI) GetContainerData(ctx context.Context, id string, kiveTrap kivev2alpha1.KiveTrap) (ContainerData, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, criTimeout)
+	defer cancel()
+
+	response, err := self.client.ContainerStatus(ctx, &runtimeapi.ContainerStatusRequest{
+		ContainerId: id,
+		Verbose:     true,
+	})
+	if status.Code(err) == codes.NotFound {
+		return ContainerData{}, fmt.Errorf("CRI GetContainerData %s: %w", id, ErrContainerNotFound)
+	}
+	if err != nil {
+		if status.Code(err) == codes.Unavailable {
+			// The connection is checked again by the next request
+			self.isConnected = false
+		}
+		return ContainerData{}, fmt.Errorf("CRI GetContainerData Error ContainerStatus %s: %w", id, err)
+	}
+
+	if response.GetStatus().GetState() != runtimeapi.ContainerState_CONTAINER_RUNNING {
+		return ContainerData{ShouldRequeue: true}, nil
+	}
+
+	pid, err := criPid(response.GetInfo())
+	if err != nil {
+		return ContainerData{}, fmt.Errorf("CRI GetContainerData Error %s: %w", id, err)
+	}
+
+	return ResolveTrap(pid, kiveTrap)
+}
+
+/*
+ *  Find the pid in the verbose information of a container. The key
+ *  of the information depends on the runtime, usually "info".
+ */
+func criPid(info map[string]string) (Pid, error) {
+
+	if value, ok := info["info"]; ok {
+		containerInfo := criContainerInfo{}
+		if err := json.Unmarshal([]byte(value), &containerInfo); err == nil && containerInfo.Pid > 0 {
+			return Pid(containerInfo.Pid), nil
+		}
+	}
+
+	for _, value := range info {
+		containerInfo := criContainerInfo{}
+		if err := json.Unmarshal([]byte(value), &containerInfo); err == nil && containerInfo.Pid > 0 {
+			return Pid(containerInfo.Pid), nil
+		}
+	}
+
+	return 0, fmt.Errorf("criPid Error No pid in the verbose status")
+}
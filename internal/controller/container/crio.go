@@ -0,0 +1,145 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	CrioAddress = "/var/run/crio/crio.sock"
+
+	// The host of the requests is ignored by the socket
+	crioURL     = "http://crio"
+	crioTimeout = 10 * time.Second
+)
+
+// Information about a container served by the CRI-O socket, only the
+// used fields
+type crioContainerInfo struct {
+	Name string `json:"name"`
+	// Pid of the init process of the container
+	Pid int `json:"pid"`
+}
+
+// Runtime of the "cri-o://" containers. The pid of a container is
+// read from the inspect endpoints that CRI-O serves on Its socket
+// next to the CRI.
+type Crio struct {
+	// Path of the unix socket of CRI-O
+	Address     string
+	client      *http.Client
+	isConnected bool
+}
+
+func (self *Crio) Connect(ctx context.Context) error {
+
+	if self.client == nil {
+		address := self.Address
+		if address == "" {
+			address = CrioAddress
+		}
+		dialer := net.Dialer{}
+		self.client = &http.Client{
+			Timeout: crioTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", address)
+				},
+			},
+		}
+	}
+
+	response, err := self.get(ctx, "/info")
+	if err != nil {
+		return fmt.Errorf("Crio Connect Error: %w", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Crio Connect Error Get info: %s", response.Status)
+	}
+
+	self.isConnected = true
+
+	return nil
+}
+
+func (self *Crio) Disconnect() error {
+
+	if self.client != nil {
+		self.client.CloseIdleConnections()
+	}
+
+	self.isConnected = false
+
+	return nil
+}
+
+func (self *Crio) IsConnected() bool {
+	return self.isConnected
+}
+
+func (self *Crio) GetContainerData(ctx context.Context, id string, kiveTrap kivev2alpha1.KiveTrap) (ContainerData, error) {
+
+	response, err := self.get(ctx, "/containers/"+url.PathEscape(id))
+	if err != nil {
+		// The connection is checked again by the next request
+		self.isConnected = false
+		return ContainerData{}, fmt.Errorf("Crio GetContainerData Error: %w", err)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ContainerData{}, fmt.Errorf("Crio GetContainerData %s: %w", id, ErrContainerNotFound)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return ContainerData{}, fmt.Errorf("Crio GetContainerData Error Inspect %s: %s %s", id, response.Status, body)
+	}
+
+	info := crioContainerInfo{}
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return ContainerData{}, fmt.Errorf("Crio GetContainerData Error Json Decode: %w", err)
+	}
+	if info.Pid <= 0 {
+		// The container is not running yet
+		return ContainerData{ShouldRequeue: true}, nil
+	}
+
+	return ResolveTrap(Pid(info.Pid), kiveTrap)
+}
+
+func (self *Crio) get(ctx context.Context, path string) (*http.Response, error) {
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, crioURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("get Error New request: %w", err)
+	}
+
+	response, err := self.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("get Error %s: %w", path, err)
+	}
+
+	return response, nil
+}
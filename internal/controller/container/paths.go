@@ -0,0 +1,375 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package container
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	// Prefixes of the paths of the traps that are patterns
+	globPrefix  = "glob:"
+	regexPrefix = "regex:"
+
+	// Characters that end the literal prefix of a regular expression
+	regexMetaCharacters = `\.+*?()|[]{}^$`
+)
+
+var (
+	// Mountpoints of the kernel filesystems, which are not walked
+	// from the directories above them
+	pseudoFilesystems = []string{"/proc", "/sys"}
+)
+
+/*
+ *  Whether the path of a trap is a glob or a regular expression
+ *  instead of a literal path
+ */
+func IsPathPattern(path string) bool {
+	return strings.HasPrefix(path, globPrefix) || strings.HasPrefix(path, regexPrefix)
+}
+
+/*
+ *  Find the files trapped by kiveTrap in the root filesystem of the
+ *  process pid, which is the main process of a container. The path
+ *  of the trap is expanded if It is a pattern, and the directories
+ *  are walked if the trap is recursive.
+ */
+func ResolveTrap(pid Pid, kiveTrap kivev2alpha1.KiveTrap) (ContainerData, error) {
+	return resolveTrap(containerRoot(pid), kiveTrap)
+}
+
+func resolveTrap(root string, kiveTrap kivev2alpha1.KiveTrap) (ContainerData, error) {
+
+	if err := checkPath(kiveTrap.Path); err != nil {
+		return ContainerData{}, fmt.Errorf("ResolveTrap Error: %w", err)
+	}
+
+	containerData := ContainerData{}
+	files := []ContainerFile{}
+	if IsPathPattern(kiveTrap.Path) {
+		expanded, err := expandPath(root, kiveTrap.Path, int(kiveTrap.MaxDepth))
+		if err != nil {
+			return ContainerData{}, fmt.Errorf("ResolveTrap Error: %w", err)
+		}
+		if len(expanded) == 0 {
+			return ContainerData{}, fmt.Errorf("ResolveTrap Error no file matches %s: %w", kiveTrap.Path, fs.ErrNotExist)
+		}
+		files = expanded
+	} else {
+		inode, dev, isDir, created, err := getInodeDev(root, kiveTrap.Path, kiveTrap.Create, kiveTrap.Mode)
+		if err != nil {
+			return ContainerData{}, err
+		}
+		containerData.Ino = inode
+		containerData.DevID = UserDevToKernelDev(dev)
+		containerData.Created = created
+		files = append(files, ContainerFile{
+			Path:  kiveTrap.Path,
+			Ino:   inode,
+			DevID: containerData.DevID,
+			isDir: isDir,
+		})
+	}
+
+	// A recursive trap on a file traps only the file
+	if kiveTrap.Recursive {
+		for _, file := range files {
+			if file.isDir {
+				tree, err := walkTree(root, file.Path, int(kiveTrap.MaxDepth), nil)
+				if err != nil {
+					return ContainerData{}, fmt.Errorf("ResolveTrap Error: %w", err)
+				}
+				files = append(files, tree...)
+			}
+		}
+	}
+
+	// The same file can be matched more than once
+	seen := map[ContainerFile]bool{}
+	for _, file := range files {
+		file.isDir = false
+		if seen[ContainerFile{Ino: file.Ino, DevID: file.DevID}] {
+			continue
+		}
+		seen[ContainerFile{Ino: file.Ino, DevID: file.DevID}] = true
+		if len(containerData.Files) >= MaxTreeFiles {
+			return ContainerData{}, fmt.Errorf("ResolveTrap Error %s: %w (%d)", kiveTrap.Path, ErrTreeTooLarge, MaxTreeFiles)
+		}
+		containerData.Files = append(containerData.Files, file)
+	}
+	containerData.IsFound = true
+
+	return containerData, nil
+}
+
+/*
+ *  Expand a glob: or regex: pattern into the paths that match It in
+ *  the root filesystem of the process pid. A regular expression must
+ *  match the whole path and start with an absolute directory, Its
+ *  literal prefix, below which the files are matched up to maxDepth
+ *  levels. The paths through symbolic links are not matched.
+ */
+func ExpandPath(pid Pid, pattern string, maxDepth int) ([]ContainerFile, error) {
+	return expandPath(containerRoot(pid), pattern, maxDepth)
+}
+
+func expandPath(root string, pattern string, maxDepth int) ([]ContainerFile, error) {
+
+	if glob, ok := strings.CutPrefix(pattern, globPrefix); ok {
+
+		matches, err := filepath.Glob(filepath.Join(root, strings.TrimSpace(glob)))
+		if err != nil {
+			return nil, fmt.Errorf("ExpandPath Error Glob %s: %w", glob, err)
+		}
+
+		files := []ContainerFile{}
+		for _, match := range matches {
+			// The symbolic links would be resolved outside of the
+			// root of the container
+			if throughSymlink(root, match) {
+				continue
+			}
+			file, err := statFile(root, match)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("ExpandPath Error: %w", err)
+			}
+			files = append(files, file)
+		}
+		return files, nil
+	}
+
+	expression := strings.TrimSpace(strings.TrimPrefix(pattern, regexPrefix))
+	compiledRegex, err := regexp.Compile("^(?:" + expression + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("ExpandPath Error compiling regex: %w", err)
+	}
+
+	// Walk only the directory that contains every match, which
+	// cannot be the root of the container
+	literal := strings.TrimPrefix(expression, "^")
+	if end := strings.IndexAny(literal, regexMetaCharacters); end >= 0 {
+		literal = literal[:end]
+	}
+	slash := strings.LastIndex(literal, "/")
+	if !strings.HasPrefix(literal, "/") || slash <= 0 {
+		return nil, fmt.Errorf("ExpandPath Error regex %s: %w", expression, ErrNoLiteralPrefix)
+	}
+	base := literal[:slash]
+
+	files, err := walkTree(root, base, maxDepth, compiledRegex.MatchString)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []ContainerFile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ExpandPath Error: %w", err)
+	}
+
+	return files, nil
+}
+
+/*
+ *  Collect the files and the directories below path in root, up to
+ *  maxDepth levels, whose path in root satisfies match, or all of
+ *  them if match is nil. Symbolic links are not followed nor
+ *  returned, the pseudo filesystems are not entered and the files
+ *  that disappear during the walk are skipped.
+ */
+func walkTree(root string, path string, maxDepth int, match func(string) bool) ([]ContainerFile, error) {
+
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+
+	target := filepath.Join(root, path)
+
+	files := []ContainerFile{}
+	err := filepath.WalkDir(target, func(walked string, entry fs.DirEntry, err error) error {
+
+		if err != nil {
+			if walked == target {
+				return err
+			}
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if walked == target {
+			if !entry.IsDir() {
+				return fmt.Errorf("walkTree Error %s: not a directory", path)
+			}
+			return nil
+		}
+
+		relative, err := filepath.Rel(target, walked)
+		if err != nil {
+			return err
+		}
+		depth := strings.Count(relative, string(filepath.Separator)) + 1
+		if entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		if entry.IsDir() && slices.Contains(pseudoFilesystems, filepath.Join(path, relative)) {
+			return fs.SkipDir
+		}
+
+		if match == nil || match(filepath.Join(path, relative)) {
+			file, err := statFile(root, walked)
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if len(files) >= MaxTreeFiles {
+				return fmt.Errorf("walkTree Error %s: %w (%d)", path, ErrTreeTooLarge, MaxTreeFiles)
+			}
+			files = append(files, file)
+		}
+
+		if entry.IsDir() && depth >= maxDepth {
+			// Do not descend below maxDepth
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walkTree Error: %w", err)
+	}
+
+	return files, nil
+}
+
+/*
+ *  Stat the file at hostPath, which is inside root, without
+ *  following It if It is a symbolic link
+ */
+func statFile(root string, hostPath string) (ContainerFile, error) {
+
+	if !insideRoot(root, hostPath) {
+		return ContainerFile{}, fmt.Errorf("statFile Error %s: %w", hostPath, ErrPathOutsideRoot)
+	}
+
+	var stat syscall.Stat_t
+	if err := syscall.Lstat(hostPath, &stat); err != nil {
+		return ContainerFile{}, fmt.Errorf("statFile Error %s: %w", hostPath, err)
+	}
+
+	return ContainerFile{
+		Path:  "/" + strings.TrimPrefix(strings.TrimPrefix(hostPath, root), "/"),
+		Ino:   stat.Ino,
+		DevID: UserDevToKernelDev(stat.Dev),
+		isDir: stat.Mode&syscall.S_IFMT == syscall.S_IFDIR,
+	}, nil
+}
+
+/*
+ *  Check that the path of a trap, which may be a pattern, has no
+ *  ".." element, which filepath.Join would resolve above the root of
+ *  the container
+ */
+func checkPath(path string) error {
+
+	for _, element := range strings.Split(path, "/") {
+		if strings.TrimSpace(element) == ".." {
+			return fmt.Errorf("checkPath Error %s: %w", path, ErrPathOutsideRoot)
+		}
+	}
+
+	return nil
+}
+
+/*
+ *  Whether hostPath is root or a path below It
+ */
+func insideRoot(root string, hostPath string) bool {
+
+	relative, err := filepath.Rel(root, hostPath)
+	if err != nil {
+		return false
+	}
+
+	return relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+/*
+ *  Whether hostPath, which is inside root, or one of Its parents
+ *  below root is a symbolic link
+ */
+func throughSymlink(root string, hostPath string) bool {
+
+	for path := hostPath; len(path) > len(root); path = filepath.Dir(path) {
+		var stat syscall.Stat_t
+		if err := syscall.Lstat(path, &stat); err != nil {
+			return true
+		}
+		if stat.Mode&syscall.S_IFMT == syscall.S_IFLNK {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+ *  Root filesystem of the process pid, as seen by the operator
+ */
+func containerRoot(pid Pid) string {
+	return ProcMountpoint + separator + strconv.FormatUint(uint64(pid), 10) + separator + "root"
+}
+
+/*
+ *  Get the inode of a file, given the path and the root filesystem
+ *  of the container where the file lives. Creates the file with mode
+ *  permissions if create is set to true and It does not exist,
+ *  in which case created is true.
+ */
+func getInodeDev(root string, path string, create bool, mode uint32) (ino Ino, dev uint64, isDir bool, created bool, err error) {
+	target := root + separator + path
+	if !insideRoot(root, filepath.Clean(target)) {
+		return uint64(0), uint64(0), false, false, fmt.Errorf("getInodeDev Error %s: %w", path, ErrPathOutsideRoot)
+	}
+	var stat syscall.Stat_t
+
+	err = syscall.Stat(target, &stat)
+	if err == syscall.ENOENT && create {
+		var fd int
+		fd, err = syscall.Open(target, syscall.O_CREAT|syscall.O_EXCL|syscall.O_WRONLY, mode)
+		if err != nil {
+			return uint64(0), uint64(0), false, false, err
+		}
+		syscall.Close(fd)
+		created = true
+
+		err = syscall.Stat(target, &stat)
+	}
+	if err != nil {
+		return uint64(0), uint64(0), false, false, err
+	}
+
+	return stat.Ino, stat.Dev, stat.Mode&syscall.S_IFMT == syscall.S_IFDIR, created, nil
+}
@@ -0,0 +1,195 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package container
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+/*
+ *  Create the files at paths in a temporary root, the paths ending
+ *  with a slash are directories
+ */
+func newTestRoot(t *testing.T, paths ...string) string {
+
+	root := t.TempDir()
+	for _, path := range paths {
+		hostPath := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(hostPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if path[len(path)-1] == '/' {
+			if err := os.MkdirAll(hostPath, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(hostPath, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func filePaths(containerData ContainerData) []string {
+
+	paths := []string{}
+	for _, file := range containerData.Files {
+		paths = append(paths, file.Path)
+	}
+	slices.Sort(paths)
+
+	return paths
+}
+
+func TestResolveTrap(t *testing.T) {
+
+	root := newTestRoot(t, "/etc/passwd", "/etc/ssl/cert.pem", "/etc/ssl/private/key.pem")
+
+	tests := []struct {
+		name     string
+		kiveTrap kivev2alpha1.KiveTrap
+		want     []string
+	}{
+		{
+			name:     "file",
+			kiveTrap: kivev2alpha1.KiveTrap{Path: "/etc/passwd"},
+			want:     []string{"/etc/passwd"},
+		},
+		{
+			name:     "recursive file",
+			kiveTrap: kivev2alpha1.KiveTrap{Path: "/etc/passwd", Recursive: true},
+			want:     []string{"/etc/passwd"},
+		},
+		{
+			name:     "recursive directory",
+			kiveTrap: kivev2alpha1.KiveTrap{Path: "/etc/ssl", Recursive: true},
+			want:     []string{"/etc/ssl", "/etc/ssl/cert.pem", "/etc/ssl/private", "/etc/ssl/private/key.pem"},
+		},
+		{
+			name:     "recursive directory with a depth limit",
+			kiveTrap: kivev2alpha1.KiveTrap{Path: "/etc/ssl", Recursive: true, MaxDepth: 1},
+			want:     []string{"/etc/ssl", "/etc/ssl/cert.pem", "/etc/ssl/private"},
+		},
+		{
+			name:     "regex",
+			kiveTrap: kivev2alpha1.KiveTrap{Path: `regex:/etc/ssl/.*\.pem`},
+			want:     []string{"/etc/ssl/cert.pem", "/etc/ssl/private/key.pem"},
+		},
+		{
+			name:     "recursive glob",
+			kiveTrap: kivev2alpha1.KiveTrap{Path: "glob:/etc/*", Recursive: true, MaxDepth: 1},
+			want:     []string{"/etc/passwd", "/etc/ssl", "/etc/ssl/cert.pem", "/etc/ssl/private"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			containerData, err := resolveTrap(root, test.kiveTrap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !containerData.IsFound {
+				t.Error("trap not found")
+			}
+			if got := filePaths(containerData); !slices.Equal(got, test.want) {
+				t.Errorf("files %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveTrapOutsideRoot(t *testing.T) {
+
+	// The file above the root must not be trapped
+	root := newTestRoot(t, "/secret", "/root/etc/passwd")
+	root = filepath.Join(root, "root")
+
+	for _, path := range []string{
+		"/../secret",
+		"/etc/../../secret",
+		"glob:/../*",
+		"glob:/etc/../../secret",
+		"regex:/etc/../../secret",
+	} {
+		t.Run(path, func(t *testing.T) {
+
+			_, err := resolveTrap(root, kivev2alpha1.KiveTrap{Path: path})
+			if !errors.Is(err, ErrPathOutsideRoot) {
+				t.Errorf("error %v, want %v", err, ErrPathOutsideRoot)
+			}
+		})
+	}
+}
+
+func TestInsideRoot(t *testing.T) {
+
+	tests := []struct {
+		hostPath string
+		want     bool
+	}{
+		{"/host/proc/1/root", true},
+		{"/host/proc/1/root/etc/passwd", true},
+		{"/host/proc/1/root/..secret", true},
+		{"/host/proc/1/secret", false},
+		{"/host/proc/1/rootfs", false},
+	}
+
+	for _, test := range tests {
+		if got := insideRoot("/host/proc/1/root", test.hostPath); got != test.want {
+			t.Errorf("insideRoot(%s) = %t, want %t", test.hostPath, got, test.want)
+		}
+	}
+}
+
+func TestExpandPathRegexPrefix(t *testing.T) {
+
+	root := newTestRoot(t, "/etc/ssl/cert.pem")
+
+	for _, pattern := range []string{
+		`regex:.*\.pem`,
+		`regex:/.*\.pem`,
+		`regex:/(etc|var)/ssl/cert\.pem`,
+	} {
+		t.Run(pattern, func(t *testing.T) {
+
+			_, err := expandPath(root, pattern, 0)
+			if !errors.Is(err, ErrNoLiteralPrefix) {
+				t.Errorf("error %v, want %v", err, ErrNoLiteralPrefix)
+			}
+		})
+	}
+}
+
+func TestResolveTrapSkipsPseudoFilesystems(t *testing.T) {
+
+	root := newTestRoot(t, "/etc/passwd", "/proc/1/environ", "/sys/kernel/")
+
+	containerData, err := resolveTrap(root, kivev2alpha1.KiveTrap{Path: "/", Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/", "/etc", "/etc/passwd"}
+	if got := filePaths(containerData); !slices.Equal(got, want) {
+		t.Errorf("files %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Clock ticks per second of the starttime of /proc/<pid>/stat,
+	// USER_HZ is 100 on every architecture supported by Linux
+	userHZ = 100
+	// Index of starttime in the fields of /proc/<pid>/stat that
+	// follow the command
+	statStartTimeField = 19
+)
+
+var (
+	ErrProcessNotFound = errors.New("Process not found")
+)
+
+// What procfs tells about a running process
+type Process struct {
+	// Path of the executable in the root of Its container
+	Executable string
+	// Current working directory in the root of Its container
+	Cwd string
+	// The command line, starting with the name of the executable
+	Args []string
+}
+
+/*
+ *  Read the executable, the working directory and the command line
+ *  of the process with thread group id pid in the initial pid
+ *  namespace, which started startTime nanoseconds after boot, from
+ *  the first of the procfs mountpoints that has It. If the node is a
+ *  container, Its procfs has other pids and the process is found
+ *  only if the start time matches. If startTime is 0, It is not
+ *  checked.
+ *
+ *  The process may have already exited when Its access is read, in
+ *  that case the returned error wraps ErrProcessNotFound.
+ */
+func ReadProcess(mountpoints []string, pid Pid, startTime uint64) (Process, error) {
+
+	var errs []error
+	for _, mountpoint := range mountpoints {
+
+		process, err := readProcess(mountpoint, pid, startTime)
+		if err == nil {
+			return process, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return Process{}, fmt.Errorf("ReadProcess Error pid %d: %w: %w", pid, ErrProcessNotFound, errors.Join(errs...))
+}
+
+/*
+ *  The procfs of the host if It is mounted, otherwise the one of the
+ *  node
+ */
+func DefaultProcMountpoints() []string {
+	return []string{RealHostProcMountpoint, ProcMountpoint}
+}
+
+func readProcess(mountpoint string, pid Pid, startTime uint64) (Process, error) {
+
+	procPath := mountpoint + separator + strconv.FormatUint(uint64(pid), 10)
+
+	if startTime != 0 {
+		stat, err := os.ReadFile(procPath + separator + "stat")
+		if err != nil {
+			return Process{}, fmt.Errorf("readProcess Error Read stat: %w", err)
+		}
+		ticks, err := parseStatStartTime(string(stat))
+		if err != nil {
+			return Process{}, fmt.Errorf("readProcess Error Parse %s/stat: %w", procPath, err)
+		}
+		if ticks != startTime/(1_000_000_000/userHZ) {
+			return Process{}, fmt.Errorf("readProcess Error %s is another process", procPath)
+		}
+	}
+
+	executable, err := os.Readlink(procPath + separator + "exe")
+	if err != nil {
+		return Process{}, fmt.Errorf("readProcess Error Readlink exe: %w", err)
+	}
+
+	cwd, err := os.Readlink(procPath + separator + "cwd")
+	if err != nil {
+		return Process{}, fmt.Errorf("readProcess Error Readlink cwd: %w", err)
+	}
+
+	cmdline, err := os.ReadFile(procPath + separator + "cmdline")
+	if err != nil {
+		return Process{}, fmt.Errorf("readProcess Error Read cmdline: %w", err)
+	}
+
+	process := Process{
+		Executable: strings.TrimSuffix(executable, " (deleted)"),
+		Cwd:        cwd,
+	}
+	// Empty for the zombies
+	if args := strings.TrimRight(string(cmdline), "\x00"); args != "" {
+		process.Args = strings.Split(args, "\x00")
+	}
+
+	return process, nil
+}
+
+/*
+ *  The starttime in clock ticks of the content of /proc/<pid>/stat.
+ *  The command is between parenthesis and may contain spaces or
+ *  parenthesis, so the fields are counted after the last one.
+ */
+func parseStatStartTime(stat string) (uint64, error) {
+
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, fmt.Errorf("parseStatStartTime Error No command")
+	}
+
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) <= statStartTimeField {
+		return 0, fmt.Errorf("parseStatStartTime Error %d fields", len(fields))
+	}
+
+	return strconv.ParseUint(fields[statStartTimeField], 10, 64)
+}
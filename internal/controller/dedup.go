@@ -0,0 +1,193 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+	metrics "github.com/San7o/kivebpf/internal/controller/metrics"
+)
+
+const (
+	// How often the dedup windows are checked for expiration
+	dedupFlushInterval = 250 * time.Millisecond
+
+	// Reasons why an alert is not sent
+	dedupSuppressed     = "dedup"
+	rateLimitSuppressed = "rate-limit"
+)
+
+// Accesses are aggregated if they share the trap, the process, the
+// container, the operation and the mask
+type dedupKey struct {
+	TrapID      string
+	Pid         int32
+	Tgid        uint32
+	ContainerID string
+	Operation   string
+	Mask        int32
+}
+
+// The accesses received during a dedup window, after the first one
+// which has already been sent
+type dedupGroup struct {
+	// The latest access of the group
+	item   delivery
+	window time.Duration
+	start  time.Time
+	count  int32
+	// Accesses suppressed by the eBPF program before the aggregated
+	// ones
+	suppressed uint32
+	firstSeen  time.Time
+	lastSeen   time.Time
+}
+
+type trapLimiter struct {
+	rateLimit kivev2alpha1.KiveRateLimit
+	limiter   *rate.Limiter
+}
+
+// Aggregates the alerts of the traps with a dedup window and limits
+// the alerts of the traps with a rate limit. The limiters of the
+// traps are dropped when they are full, so that the removed traps
+// are forgotten. It is safe for concurrent use by the workers of the
+// pipeline.
+type Deduplicator struct {
+	mutex    sync.Mutex
+	groups   map[dedupKey]*dedupGroup
+	limiters map[string]*trapLimiter
+}
+
+func NewDeduplicator() *Deduplicator {
+	return &Deduplicator{
+		groups:   map[dedupKey]*dedupGroup{},
+		limiters: map[string]*trapLimiter{},
+	}
+}
+
+/*
+ *  Add an alert received at now. The returned alerts, if any, should
+ *  be delivered right away.
+ */
+func (self *Deduplicator) Add(item delivery, window time.Duration, rateLimit *kivev2alpha1.KiveRateLimit, now time.Time) []delivery {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if window <= 0 {
+		return self.limit(item, rateLimit, now)
+	}
+
+	key := dedupKey{
+		TrapID:      item.kiveData.Labels[TrapIDLabel],
+		Pid:         item.alert.Process.Pid,
+		Tgid:        item.alert.Process.Tgid,
+		ContainerID: item.alert.Pod.Container.Id,
+		Operation:   item.alert.Metadata.Operation,
+		Mask:        item.alert.Metadata.Mask,
+	}
+	group, ok := self.groups[key]
+	if ok {
+		if group.count == 0 {
+			group.firstSeen = now
+		}
+		group.item = item
+		group.count++
+		group.suppressed += item.alert.Suppressed
+		group.lastSeen = now
+		metrics.AlertsSuppressed.WithLabelValues(item.alert.PolicyName, item.kiveData.Labels[TrapIDLabel], dedupSuppressed).Inc()
+		return nil
+	}
+
+	self.groups[key] = &dedupGroup{
+		item:   item,
+		window: window,
+		start:  now,
+	}
+	item.alert.Count = 1
+	item.alert.FirstSeen = now.Format(time.RFC3339)
+	item.alert.LastSeen = item.alert.FirstSeen
+
+	return self.limit(item, rateLimit, now)
+}
+
+/*
+ *  Close the dedup windows that ended before now, or all of them if
+ *  all is set, and return the aggregated alerts. The idle limiters
+ *  are dropped.
+ */
+func (self *Deduplicator) Flush(now time.Time, all bool) []delivery {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	items := []delivery{}
+	for key, group := range self.groups {
+		if !all && now.Sub(group.start) < group.window {
+			continue
+		}
+		delete(self.groups, key)
+		if group.count == 0 {
+			continue
+		}
+
+		item := group.item
+		item.alert.Timestamp = now.Format(time.RFC3339)
+		item.alert.Count = group.count
+		item.alert.Suppressed = group.suppressed
+		item.alert.FirstSeen = group.firstSeen.Format(time.RFC3339)
+		item.alert.LastSeen = group.lastSeen.Format(time.RFC3339)
+
+		rateLimit, _ := KiveDataRateLimit(item.kiveData)
+		items = append(items, self.limit(item, rateLimit, now)...)
+	}
+
+	// A full limiter behaves like a new one
+	for trapID, limiter := range self.limiters {
+		if limiter.limiter.TokensAt(now) >= float64(limiter.limiter.Burst()) {
+			delete(self.limiters, trapID)
+		}
+	}
+
+	return items
+}
+
+func (self *Deduplicator) limit(item delivery, rateLimit *kivev2alpha1.KiveRateLimit, now time.Time) []delivery {
+
+	trapID := item.kiveData.Labels[TrapIDLabel]
+	if rateLimit == nil || rateLimit.Burst <= 0 {
+		delete(self.limiters, trapID)
+		return []delivery{item}
+	}
+
+	limiter, ok := self.limiters[trapID]
+	if !ok || limiter.rateLimit != *rateLimit {
+		limiter = &trapLimiter{
+			rateLimit: *rateLimit,
+			limiter:   rate.NewLimiter(rate.Every(rateLimit.Interval.Duration), int(rateLimit.Burst)),
+		}
+		self.limiters[trapID] = limiter
+	}
+
+	if !limiter.limiter.AllowN(now, 1) {
+		metrics.AlertsSuppressed.WithLabelValues(item.alert.PolicyName, item.kiveData.Labels[TrapIDLabel], rateLimitSuppressed).Inc()
+		return []delivery{}
+	}
+
+	return []delivery{item}
+}
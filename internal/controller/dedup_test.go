@@ -0,0 +1,72 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+var (
+	dedupRateLimit = &kivev2alpha1.KiveRateLimit{
+		Burst:    1,
+		Interval: metav1.Duration{Duration: time.Minute},
+	}
+	dedupItem = delivery{
+		kiveData: kivev2alpha1.KiveData{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{TrapIDLabel: "dedup-trap"},
+			},
+		},
+	}
+)
+
+func TestDeduplicatorRateLimit(t *testing.T) {
+
+	dedup := NewDeduplicator()
+	now := time.Now()
+
+	if got := dedup.Add(dedupItem, 0, dedupRateLimit, now); len(got) != 1 {
+		t.Fatalf("Add first alert = %d deliveries, want 1", len(got))
+	}
+	if got := dedup.Add(dedupItem, 0, dedupRateLimit, now.Add(time.Second)); len(got) != 0 {
+		t.Fatalf("Add alert over the limit = %d deliveries, want 0", len(got))
+	}
+	if got := dedup.Add(dedupItem, 0, dedupRateLimit, now.Add(time.Minute)); len(got) != 1 {
+		t.Fatalf("Add alert after the interval = %d deliveries, want 1", len(got))
+	}
+}
+
+func TestDeduplicatorDropIdleLimiters(t *testing.T) {
+
+	dedup := NewDeduplicator()
+	now := time.Now()
+
+	if got := dedup.Add(dedupItem, 0, dedupRateLimit, now); len(got) != 1 {
+		t.Fatalf("Add first alert = %d deliveries, want 1", len(got))
+	}
+
+	dedup.Flush(now.Add(time.Second), false)
+	if _, ok := dedup.limiters["dedup-trap"]; !ok {
+		t.Fatalf("Flush dropped the limiter of an active trap")
+	}
+
+	dedup.Flush(now.Add(time.Minute), false)
+	if len(dedup.limiters) != 0 {
+		t.Fatalf("Flush kept %d limiters of idle traps, want 0", len(dedup.limiters))
+	}
+}
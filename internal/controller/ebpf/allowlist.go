@@ -0,0 +1,170 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/cilium/ebpf"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	AllowlistMaxEntries = 4096
+
+	// Kinds of the ids in the allowed_ids map
+	allowUid uint32 = 0
+	allowGid uint32 = 1
+	allowPid uint32 = 2
+
+	// Length of the comm of a task, without the terminating zero
+	commMaxLength = 15
+)
+
+// The entries of the allowlist maps of each traced file, so that
+// they can be updated and removed without iterating the maps
+type allowEntries struct {
+	comms map[bpfAllowCommKey]bool
+	ids   map[bpfAllowIdKey]bool
+}
+
+var (
+	allowlistsMutex sync.Mutex
+	allowlists      = map[BpfMapKey]allowEntries{}
+)
+
+/*
+ *  Replace the allowlist of a traced file. The accesses of the
+ *  processes in allow are ignored by the eBPF program.
+ */
+func SetAllowlist(mapKey BpfMapKey, allow *kivev2alpha1.KiveTrapAllow) error {
+
+	allowlistsMutex.Lock()
+	defer allowlistsMutex.Unlock()
+
+	entries := allowEntries{
+		comms: map[bpfAllowCommKey]bool{},
+		ids:   map[bpfAllowIdKey]bool{},
+	}
+	if allow != nil {
+		for _, binary := range allow.Binaries {
+			entries.comms[allowCommKey(mapKey, binary)] = true
+		}
+		for _, uid := range allow.Uids {
+			entries.ids[allowIdKey(mapKey, allowUid, uid)] = true
+		}
+		for _, gid := range allow.Gids {
+			entries.ids[allowIdKey(mapKey, allowGid, gid)] = true
+		}
+		for _, pid := range allow.Pids {
+			entries.ids[allowIdKey(mapKey, allowPid, pid)] = true
+		}
+	}
+
+	previous := allowlists[mapKey]
+	var errs []error
+	for key := range previous.comms {
+		if entries.comms[key] {
+			continue
+		}
+		if err := Objs.AllowedComms.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			errs = append(errs, fmt.Errorf("SetAllowlist Error Delete binary: %w", err))
+		}
+	}
+	for key := range previous.ids {
+		if entries.ids[key] {
+			continue
+		}
+		if err := Objs.AllowedIds.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			errs = append(errs, fmt.Errorf("SetAllowlist Error Delete id: %w", err))
+		}
+	}
+
+	one := uint8(1)
+	for key := range entries.comms {
+		if previous.comms[key] {
+			continue
+		}
+		if err := Objs.AllowedComms.Update(key, one, ebpf.UpdateAny); err != nil {
+			delete(entries.comms, key)
+			errs = append(errs, fmt.Errorf("SetAllowlist Error Update binary: %w", err))
+		}
+	}
+	for key := range entries.ids {
+		if previous.ids[key] {
+			continue
+		}
+		if err := Objs.AllowedIds.Update(key, one, ebpf.UpdateAny); err != nil {
+			delete(entries.ids, key)
+			errs = append(errs, fmt.Errorf("SetAllowlist Error Update id: %w", err))
+		}
+	}
+
+	if len(entries.comms) == 0 && len(entries.ids) == 0 {
+		delete(allowlists, mapKey)
+	} else {
+		allowlists[mapKey] = entries
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+ *  Whether the process of an eBPF event is in allow
+ */
+func isAllowed(allow *kivev2alpha1.KiveTrapAllow, data BpfLogData) bool {
+
+	if allow == nil {
+		return false
+	}
+
+	mapKey := BpfMapKey{Inode: data.Ino, Dev: data.Dev}
+	for _, binary := range allow.Binaries {
+		if allowCommKey(mapKey, binary).Comm == data.Comm {
+			return true
+		}
+	}
+
+	return slices.Contains(allow.Uids, data.Uid) ||
+		slices.Contains(allow.Gids, data.Gid) ||
+		slices.Contains(allow.Pids, data.NsTgid)
+}
+
+/*
+ *  Remove the allowlist of a traced file
+ */
+func RemoveAllowlist(mapKey BpfMapKey) error {
+	return SetAllowlist(mapKey, nil)
+}
+
+func allowCommKey(mapKey BpfMapKey, binary string) bpfAllowCommKey {
+
+	key := bpfAllowCommKey{Inode: mapKey.Inode, Dev: mapKey.Dev}
+	// The kernel truncates the comm of the tasks
+	if len(binary) > commMaxLength {
+		binary = binary[:commMaxLength]
+	}
+	for i := 0; i < len(binary); i++ {
+		key.Comm[i] = int8(binary[i])
+	}
+
+	return key
+}
+
+func allowIdKey(mapKey BpfMapKey, kind uint32, id uint32) bpfAllowIdKey {
+	return bpfAllowIdKey{Inode: mapKey.Inode, Dev: mapKey.Dev, Kind: kind, Id: id}
+}
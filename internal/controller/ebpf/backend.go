@@ -0,0 +1,212 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package ebpf
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/features"
+	"github.com/cilium/ebpf/link"
+)
+
+// A Backend is the kind of eBPF program that traces the permission
+// checks of the inodes
+type Backend string
+
+const (
+	// Use the first supported backend of Backends
+	BackendAuto Backend = "auto"
+	// BPF LSM program on the inode_permission hook
+	BackendLSM Backend = "lsm"
+	// fentry program on security_inode_permission
+	BackendFentry Backend = "fentry"
+	// kprobe on inode_permission
+	BackendKprobe Backend = "kprobe"
+)
+
+var (
+	// Backend requested by the user, BackendAuto to detect It
+	RequestedBackend Backend = BackendAuto
+	// Backend of the loaded program, empty if none is loaded
+	ActiveBackend Backend = ""
+	// The backends tried by BackendAuto, in order of preference
+	Backends = []Backend{BackendLSM, BackendFentry, BackendKprobe}
+	// List of the active LSMs of the kernel
+	LsmListPath = "/sys/kernel/security/lsm"
+)
+
+// A program of the collection and the field of the objects It is
+// assigned to
+type backendProgram struct {
+	name    string
+	program **ebpf.Program
+}
+
+/*
+ *  Check whether the kernel supports backend, without loading any
+ *  program.
+ */
+func backendSupported(backend Backend) error {
+
+	switch backend {
+	case BackendLSM:
+		if err := features.HaveProgramType(ebpf.LSM); err != nil {
+			return fmt.Errorf("backendSupported Error LSM programs not supported: %w", err)
+		}
+		// The programs can be loaded even if the hooks of BPF LSM are
+		// not called
+		lsms, err := os.ReadFile(LsmListPath)
+		if err != nil {
+			return fmt.Errorf("backendSupported Error Read active LSMs: %w", err)
+		}
+		active := strings.TrimSpace(string(lsms))
+		if !slices.Contains(strings.Split(active, ","), "bpf") {
+			return fmt.Errorf("backendSupported Error BPF LSM is not active, the active LSMs are %q", active)
+		}
+	case BackendFentry:
+		if err := features.HaveProgramType(ebpf.Tracing); err != nil {
+			return fmt.Errorf("backendSupported Error Tracing programs not supported: %w", err)
+		}
+	case BackendKprobe:
+		if err := features.HaveProgramType(ebpf.Kprobe); err != nil {
+			return fmt.Errorf("backendSupported Error Kprobe programs not supported: %w", err)
+		}
+	default:
+		return fmt.Errorf("backendSupported Error Unknown backend %q", backend)
+	}
+
+	// The programs are attached by BTF id or read the arguments of
+	// the probed function from BTF
+	if _, err := btf.LoadKernelSpec(); err != nil {
+		return fmt.Errorf("backendSupported Error Load kernel BTF: %w", err)
+	}
+
+	return nil
+}
+
+/*
+ *  The programs of the permission checks loaded by backend into
+ *  objs.
+ */
+func backendPrograms(backend Backend, objs *bpfObjects) ([]backendProgram, error) {
+
+	switch backend {
+	case BackendLSM:
+		return []backendProgram{{"lsm_inode_permission", &objs.LsmInodePermission}}, nil
+	case BackendFentry:
+		return []backendProgram{{"fentry_inode_permission", &objs.FentryInodePermission}}, nil
+	case BackendKprobe:
+		// The function has an idmap argument since linux 5.12
+		params, err := kernelFuncParams(KprobedFunc)
+		if err != nil {
+			return nil, fmt.Errorf("backendPrograms Error: %w", err)
+		}
+		if params > 2 {
+			return []backendProgram{{"kprobe_inode_permission_new", &objs.KprobeInodePermissionNew}}, nil
+		}
+		return []backendProgram{{"kprobe_inode_permission_old", &objs.KprobeInodePermissionOld}}, nil
+	}
+
+	return nil, fmt.Errorf("backendPrograms Error Unknown backend %q", backend)
+}
+
+/*
+ *  The programs of the operations that tamper with a file, loaded
+ *  by every backend.
+ */
+func tamperPrograms(objs *bpfObjects) []backendProgram {
+
+	return []backendProgram{
+		{"kprobe_inode_unlink", &objs.KprobeInodeUnlink},
+		{"kprobe_inode_rename", &objs.KprobeInodeRename},
+		{"kprobe_inode_setattr_old", &objs.KprobeInodeSetattrOld},
+		{"kprobe_inode_setattr_new", &objs.KprobeInodeSetattrNew},
+	}
+}
+
+/*
+ *  Attach the program of the permission checks of backend.
+ */
+func attachBackend(backend Backend, objs *bpfObjects) (link.Link, error) {
+
+	switch backend {
+	case BackendLSM:
+		return link.AttachLSM(link.LSMOptions{Program: objs.LsmInodePermission})
+	case BackendFentry:
+		return link.AttachTracing(link.TracingOptions{Program: objs.FentryInodePermission})
+	case BackendKprobe:
+		program := objs.KprobeInodePermissionNew
+		if program == nil {
+			program = objs.KprobeInodePermissionOld
+		}
+		return link.Kprobe(KprobedFunc, program, nil)
+	}
+
+	return nil, fmt.Errorf("attachBackend Error Unknown backend %q", backend)
+}
+
+/*
+ *  Load the maps, the programs of backend and the programs of the
+ *  tampering operations from spec, then attach the program of the
+ *  permission checks. The other programs of spec are not loaded
+ *  since the kernel may reject them. On success, the objects are
+ *  stored in Objs.
+ */
+func loadBackend(spec *ebpf.CollectionSpec, backend Backend) (link.Link, error) {
+
+	objs := bpfObjects{}
+	programs, err := backendPrograms(backend, &objs)
+	if err != nil {
+		return nil, fmt.Errorf("loadBackend Error: %w", err)
+	}
+	programs = append(programs, tamperPrograms(&objs)...)
+
+	subset := spec.Copy()
+	for name := range subset.Programs {
+		if !slices.ContainsFunc(programs, func(program backendProgram) bool { return program.name == name }) {
+			delete(subset.Programs, name)
+		}
+	}
+
+	collection, err := ebpf.NewCollection(subset)
+	if err != nil {
+		return nil, fmt.Errorf("loadBackend Error Load eBPF objects: %w", err)
+	}
+	// Closes only the objects that were not assigned
+	defer collection.Close()
+
+	if err := collection.Assign(&objs.bpfMaps); err != nil {
+		return nil, fmt.Errorf("loadBackend Error Assign eBPF maps: %w", err)
+	}
+	if err := collection.Assign(&objs.bpfVariables); err != nil {
+		objs.Close()
+		return nil, fmt.Errorf("loadBackend Error Assign eBPF variables: %w", err)
+	}
+	for _, program := range programs {
+		*program.program = collection.DetachProgram(program.name)
+	}
+
+	permissionLink, err := attachBackend(backend, &objs)
+	if err != nil {
+		objs.Close()
+		return nil, fmt.Errorf("loadBackend Error Attach %s program: %w", backend, err)
+	}
+
+	Objs = objs
+	return permissionLink, nil
+}
@@ -13,18 +13,42 @@ import (
 	"github.com/cilium/ebpf"
 )
 
+type bpfAllowCommKey struct {
+	_     structs.HostLayout
+	Inode uint64
+	Dev   uint32
+	Comm  [16]int8
+	_     [4]byte
+}
+
+type bpfAllowIdKey struct {
+	_     structs.HostLayout
+	Inode uint64
+	Dev   uint32
+	Kind  uint32
+	Id    uint32
+	_     [4]byte
+}
+
 type bpfLogData struct {
-	_    structs.HostLayout
-	Pid  int32
-	Tgid uint32
-	Uid  uint32
-	Gid  uint32
-	Dev  uint32
-	_    [4]byte
-	Ino  uint64
-	Mask int32
-	Comm [16]int8
-	_    [4]byte
+	_          structs.HostLayout
+	Pid        int32
+	Tgid       uint32
+	Uid        uint32
+	Gid        uint32
+	Dev        uint32
+	_          [4]byte
+	Ino        uint64
+	Mask       int32
+	Comm       [16]int8
+	Suppressed uint32
+	Operation  uint32
+	Blocked    uint32
+	NsPid      uint32
+	NsTgid     uint32
+	StartTime  uint64
+	Exe        [256]int8
+	Cwd        [256]int8
 }
 
 type bpfMapKey struct {
@@ -34,6 +58,20 @@ type bpfMapKey struct {
 	_     [4]byte
 }
 
+type bpfRateLimitKey struct {
+	_     structs.HostLayout
+	Inode uint64
+	Dev   uint32
+	Tgid  uint32
+}
+
+type bpfRateLimitValue struct {
+	_          structs.HostLayout
+	LastNs     uint64
+	Suppressed uint32
+	_          [4]byte
+}
+
 // loadBpf returns the embedded CollectionSpec for bpf.
 func loadBpf() (*ebpf.CollectionSpec, error) {
 	reader := bytes.NewReader(_BpfBytes)
@@ -76,14 +114,25 @@ type bpfSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfProgramSpecs struct {
+	FentryInodePermission    *ebpf.ProgramSpec `ebpf:"fentry_inode_permission"`
 	KprobeInodePermissionNew *ebpf.ProgramSpec `ebpf:"kprobe_inode_permission_new"`
 	KprobeInodePermissionOld *ebpf.ProgramSpec `ebpf:"kprobe_inode_permission_old"`
+	KprobeInodeRename        *ebpf.ProgramSpec `ebpf:"kprobe_inode_rename"`
+	KprobeInodeSetattrNew    *ebpf.ProgramSpec `ebpf:"kprobe_inode_setattr_new"`
+	KprobeInodeSetattrOld    *ebpf.ProgramSpec `ebpf:"kprobe_inode_setattr_old"`
+	KprobeInodeUnlink        *ebpf.ProgramSpec `ebpf:"kprobe_inode_unlink"`
+	LsmInodePermission       *ebpf.ProgramSpec `ebpf:"lsm_inode_permission"`
 }
 
 // bpfMapSpecs contains maps before they are loaded into the kernel.
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfMapSpecs struct {
+	AllowedComms *ebpf.MapSpec `ebpf:"allowed_comms"`
+	AllowedIds   *ebpf.MapSpec `ebpf:"allowed_ids"`
+	DeniedInodes *ebpf.MapSpec `ebpf:"denied_inodes"`
+	LostEvents   *ebpf.MapSpec `ebpf:"lost_events"`
+	RateLimited  *ebpf.MapSpec `ebpf:"rate_limited"`
 	Rb           *ebpf.MapSpec `ebpf:"rb"`
 	TracedInodes *ebpf.MapSpec `ebpf:"traced_inodes"`
 }
@@ -92,6 +141,7 @@ type bpfMapSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfVariableSpecs struct {
+	RateLimitNs *ebpf.VariableSpec `ebpf:"rate_limit_ns"`
 }
 
 // bpfObjects contains all objects after they have been loaded into the kernel.
@@ -114,12 +164,22 @@ func (o *bpfObjects) Close() error {
 //
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfMaps struct {
+	AllowedComms *ebpf.Map `ebpf:"allowed_comms"`
+	AllowedIds   *ebpf.Map `ebpf:"allowed_ids"`
+	DeniedInodes *ebpf.Map `ebpf:"denied_inodes"`
+	LostEvents   *ebpf.Map `ebpf:"lost_events"`
+	RateLimited  *ebpf.Map `ebpf:"rate_limited"`
 	Rb           *ebpf.Map `ebpf:"rb"`
 	TracedInodes *ebpf.Map `ebpf:"traced_inodes"`
 }
 
 func (m *bpfMaps) Close() error {
 	return _BpfClose(
+		m.AllowedComms,
+		m.AllowedIds,
+		m.DeniedInodes,
+		m.LostEvents,
+		m.RateLimited,
 		m.Rb,
 		m.TracedInodes,
 	)
@@ -129,20 +189,33 @@ func (m *bpfMaps) Close() error {
 //
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfVariables struct {
+	RateLimitNs *ebpf.Variable `ebpf:"rate_limit_ns"`
 }
 
 // bpfPrograms contains all programs after they have been loaded into the kernel.
 //
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfPrograms struct {
+	FentryInodePermission    *ebpf.Program `ebpf:"fentry_inode_permission"`
 	KprobeInodePermissionNew *ebpf.Program `ebpf:"kprobe_inode_permission_new"`
 	KprobeInodePermissionOld *ebpf.Program `ebpf:"kprobe_inode_permission_old"`
+	KprobeInodeRename        *ebpf.Program `ebpf:"kprobe_inode_rename"`
+	KprobeInodeSetattrNew    *ebpf.Program `ebpf:"kprobe_inode_setattr_new"`
+	KprobeInodeSetattrOld    *ebpf.Program `ebpf:"kprobe_inode_setattr_old"`
+	KprobeInodeUnlink        *ebpf.Program `ebpf:"kprobe_inode_unlink"`
+	LsmInodePermission       *ebpf.Program `ebpf:"lsm_inode_permission"`
 }
 
 func (p *bpfPrograms) Close() error {
 	return _BpfClose(
+		p.FentryInodePermission,
 		p.KprobeInodePermissionNew,
 		p.KprobeInodePermissionOld,
+		p.KprobeInodeRename,
+		p.KprobeInodeSetattrNew,
+		p.KprobeInodeSetattrOld,
+		p.KprobeInodeUnlink,
+		p.LsmInodePermission,
 	)
 }
 
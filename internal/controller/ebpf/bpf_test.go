@@ -0,0 +1,158 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package ebpf
+
+import (
+	"testing"
+	"unsafe"
+
+	cebpf "github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+)
+
+/*
+ *  The embedded objects are built by make generate-ebpf, these tests
+ *  fail if they are older than the bindings.
+ */
+func loadTestSpec(t *testing.T) *cebpf.CollectionSpec {
+
+	spec, err := loadBpf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spec.Assign(&bpfSpecs{}); err != nil {
+		t.Fatalf("The object does not match the bindings: %v", err)
+	}
+
+	return spec
+}
+
+func TestBpfMaps(t *testing.T) {
+
+	spec := loadTestSpec(t)
+
+	tests := []struct {
+		name      string
+		mapType   cebpf.MapType
+		keySize   uintptr
+		valueSize uintptr
+	}{
+		{"denied_inodes", cebpf.Hash, unsafe.Sizeof(bpfMapKey{}), unsafe.Sizeof(uint8(0))},
+		{"rate_limited", cebpf.LRUHash, unsafe.Sizeof(bpfRateLimitKey{}), unsafe.Sizeof(bpfRateLimitValue{})},
+		{"allowed_comms", cebpf.Hash, unsafe.Sizeof(bpfAllowCommKey{}), unsafe.Sizeof(uint8(0))},
+		{"allowed_ids", cebpf.Hash, unsafe.Sizeof(bpfAllowIdKey{}), unsafe.Sizeof(uint8(0))},
+		// Summed over the cpus by CountLostEvents
+		{"lost_events", cebpf.PerCPUArray, unsafe.Sizeof(uint32(0)), unsafe.Sizeof(uint64(0))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			mapSpec, ok := spec.Maps[test.name]
+			if !ok {
+				t.Fatal("map not found")
+			}
+			if mapSpec.Type != test.mapType {
+				t.Errorf("type %s, want %s", mapSpec.Type, test.mapType)
+			}
+			if mapSpec.KeySize != uint32(test.keySize) || mapSpec.ValueSize != uint32(test.valueSize) {
+				t.Errorf("key and value of %d and %d bytes, want %d and %d",
+					mapSpec.KeySize, mapSpec.ValueSize, test.keySize, test.valueSize)
+			}
+		})
+	}
+}
+
+func TestBpfPrograms(t *testing.T) {
+
+	spec := loadTestSpec(t)
+
+	tests := []struct {
+		name     string
+		progType cebpf.ProgramType
+		attachTo string
+	}{
+		// One of them is loaded, depending on the backend
+		{"lsm_inode_permission", cebpf.LSM, "inode_permission"},
+		{"fentry_inode_permission", cebpf.Tracing, "security_inode_permission"},
+		{"kprobe_inode_permission_new", cebpf.Kprobe, "inode_permission"},
+		{"kprobe_inode_permission_old", cebpf.Kprobe, "inode_permission"},
+		// Tampering with the traced files
+		{"kprobe_inode_unlink", cebpf.Kprobe, "security_inode_unlink"},
+		{"kprobe_inode_rename", cebpf.Kprobe, "security_inode_rename"},
+		{"kprobe_inode_setattr_new", cebpf.Kprobe, "security_inode_setattr"},
+		{"kprobe_inode_setattr_old", cebpf.Kprobe, "security_inode_setattr"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			progSpec, ok := spec.Programs[test.name]
+			if !ok {
+				t.Fatal("program not found")
+			}
+			if progSpec.Type != test.progType || progSpec.AttachTo != test.attachTo {
+				t.Errorf("%s attached to %s, want %s attached to %s",
+					progSpec.Type, progSpec.AttachTo, test.progType, test.attachTo)
+			}
+		})
+	}
+}
+
+func TestBpfRateLimitInterval(t *testing.T) {
+
+	spec := loadTestSpec(t)
+
+	variable, ok := spec.Variables["rate_limit_ns"]
+	if !ok {
+		t.Fatal("rate_limit_ns not found")
+	}
+	if !variable.Constant() || variable.Size() != 8 {
+		t.Errorf("rate_limit_ns is not a constant of 8 bytes")
+	}
+	if err := variable.Set(uint64(1)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBpfLogData(t *testing.T) {
+
+	spec := loadTestSpec(t)
+
+	var logData *btf.Struct
+	if err := spec.Types.TypeByName("log_data", &logData); err != nil {
+		t.Fatal(err)
+	}
+	if logData.Size != uint32(unsafe.Sizeof(bpfLogData{})) {
+		t.Fatalf("struct log_data has %d bytes, the bindings %d", logData.Size, unsafe.Sizeof(bpfLogData{}))
+	}
+
+	offsets := map[string]uintptr{
+		"start_time": unsafe.Offsetof(bpfLogData{}.StartTime),
+		"exe":        unsafe.Offsetof(bpfLogData{}.Exe),
+		"cwd":        unsafe.Offsetof(bpfLogData{}.Cwd),
+	}
+	for _, member := range logData.Members {
+		offset, ok := offsets[member.Name]
+		if !ok {
+			continue
+		}
+		delete(offsets, member.Name)
+		if uintptr(member.Offset.Bytes()) != offset {
+			t.Errorf("%s is at byte %d, in the bindings at %d", member.Name, member.Offset.Bytes(), offset)
+		}
+	}
+	for name := range offsets {
+		t.Errorf("%s not found", name)
+	}
+}
@@ -19,31 +19,91 @@ import (
 	"errors"
 	"fmt"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/ringbuf"
 	"github.com/cilium/ebpf/rlimit"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
 	comm "github.com/San7o/kivebpf/internal/controller/comm"
+	container "github.com/San7o/kivebpf/internal/controller/container"
+	metrics "github.com/San7o/kivebpf/internal/controller/metrics"
 )
 
 const (
 	MapMaxEntries = 1024
 	KprobedFunc   = "inode_permission"
+
+	// Functions probed to detect the tampering with a traced file
+	UnlinkKprobedFunc  = "security_inode_unlink"
+	RenameKprobedFunc  = "security_inode_rename"
+	SetattrKprobedFunc = "security_inode_setattr"
+
+	// Values of the operation of the eBPF events
+	opPermission uint32 = 0
+	opUnlink     uint32 = 1
+	opRename     uint32 = 2
+	opSetattr    uint32 = 3
+
+	// Bits of the access mask of inode_permission, also used as the
+	// values of the traced_inodes map
+	AccessExec   uint8 = 0x01
+	AccessWrite  uint8 = 0x02
+	AccessRead   uint8 = 0x04
+	AccessAppend uint8 = 0x08
+	AccessAccess uint8 = 0x10
+	AccessOpen   uint8 = 0x20
+	// Value of the traced_inodes map that alerts on any access
+	AccessAll uint8 = 0xff
+
+	// Values of the denied_inodes map, also reported in the eBPF
+	// events of the accesses they applied to
+	DenyEnforce uint8 = 1
+	DenyDryRun  uint8 = 2
 )
 
 var (
 	RingbuffReader *ringbuf.Reader = nil
 	Objs           bpfObjects      = bpfObjects{}
-	Kprobe         link.Link       = nil
-	Loaded         bool            = false
+	// Link of the program of the permission checks
+	PermissionLink link.Link = nil
+	// Probes of the unlink, rename and setattr operations
+	TamperKprobes []link.Link = nil
+	Loaded        bool        = false
+	// Index of the reconcilers and the alert pipelines that are not
+	// given one
+	DefaultIndex *InodeIndex = NewInodeIndex()
+
+	// Returned when an access should be denied by a backend that
+	// cannot deny It
+	ErrDenyNotSupported = errors.New("denying accesses requires the lsm backend")
+	// Minimum time between two alerts of the same process on the
+	// same file, the accesses in between are counted in the kernel
+	// and reported in the next alert. Zero disables the rate limit.
+	RateLimitInterval time.Duration = 0
 )
 
+var operations = map[uint32]string{
+	opPermission: kivev2alpha1.KiveOperationAccess,
+	opUnlink:     kivev2alpha1.KiveOperationUnlink,
+	opRename:     kivev2alpha1.KiveOperationRename,
+	opSetattr:    kivev2alpha1.KiveOperationSetattr,
+}
+
+var accessBits = map[kivev2alpha1.KiveAccess]uint8{
+	kivev2alpha1.KiveAccessExec:   AccessExec,
+	kivev2alpha1.KiveAccessWrite:  AccessWrite,
+	kivev2alpha1.KiveAccessRead:   AccessRead,
+	kivev2alpha1.KiveAccessAppend: AccessAppend,
+	kivev2alpha1.KiveAccessAccess: AccessAccess,
+	kivev2alpha1.KiveAccessOpen:   AccessOpen,
+}
+
 type BpfMapKey = bpfMapKey
+type BpfLogData = bpfLogData
 
 /*
  *  Loads the eBPF objects, the eBPF program and opens the ring
@@ -57,26 +117,57 @@ func LoadEbpf(ctx context.Context) error {
 		return fmt.Errorf("LoadEbpf Error Remove memlock: %w", err)
 	}
 
-	if err = loadBpfObjects(&Objs, nil); err != nil {
-		return fmt.Errorf("LoadEbpf Error Load eBPF objects: %w", err)
+	spec, err := loadBpf()
+	if err != nil {
+		return fmt.Errorf("LoadEbpf Error Load eBPF spec: %w", err)
 	}
 
-	kernelIsOld, err := isKernelOld()
-	if err != nil {
-		return fmt.Errorf("LoadEbpf Error Check kernel version: %w", err)
+	specs := bpfSpecs{}
+	if err = spec.Assign(&specs); err != nil {
+		return fmt.Errorf("LoadEbpf Error Assign eBPF spec: %w", err)
+	}
+	if err = specs.RateLimitNs.Set(uint64(RateLimitInterval.Nanoseconds())); err != nil {
+		return fmt.Errorf("LoadEbpf Error Set rate limit interval: %w", err)
 	}
 
-	if kernelIsOld {
-		Kprobe, err = link.Kprobe(KprobedFunc, Objs.KprobeInodePermissionOld, nil)
-		if err != nil {
-			return fmt.Errorf("LoadEbpf Error Open kprobe (old kernel): %w", err)
+	log := log.FromContext(ctx)
+
+	candidates := Backends
+	if RequestedBackend != BackendAuto && RequestedBackend != "" {
+		candidates = []Backend{RequestedBackend}
+	}
+
+	// Use the first backend the kernel accepts
+	var backendErrs []error
+	for _, backend := range candidates {
+		if err := backendSupported(backend); err != nil {
+			backendErrs = append(backendErrs, err)
+			continue
 		}
-	} else {
-		Kprobe, err = link.Kprobe(KprobedFunc, Objs.KprobeInodePermissionNew, nil)
+		PermissionLink, err = loadBackend(spec, backend)
 		if err != nil {
-			return fmt.Errorf("LoadEbpf Error Open kprobe: %w", err)
+			backendErrs = append(backendErrs, err)
+			continue
 		}
+		ActiveBackend = backend
+		break
 	}
+	if ActiveBackend == "" {
+		return fmt.Errorf("LoadEbpf Error No eBPF backend could be loaded: %w", errors.Join(backendErrs...))
+	}
+	if len(backendErrs) > 0 {
+		log.Info("Skipped the unsupported eBPF backends", "reasons", errors.Join(backendErrs...).Error())
+	}
+	log.Info("Loaded eBPF programs", "backend", ActiveBackend)
+	metrics.EbpfBackend.WithLabelValues(string(ActiveBackend)).Set(1)
+
+	TamperKprobes, err = attachTamperKprobes()
+	if err != nil {
+		// The accesses are still traced
+		log.Error(err, "LoadEbpf Error Open tampering kprobes, unlink, rename and setattr are not traced")
+	}
+
+	metrics.TracedInodesMax.Set(float64(Objs.TracedInodes.MaxEntries()))
 
 	RingbuffReader, err = ringbuf.NewReader(Objs.Rb)
 	if err != nil {
@@ -87,17 +178,68 @@ func LoadEbpf(ctx context.Context) error {
 	return nil
 }
 
+/*
+ *  Attach the probes of the operations that tamper with a file. The
+ *  probes that could be attached are returned even if others
+ *  failed.
+ */
+func attachTamperKprobes() ([]link.Link, error) {
+
+	links := []link.Link{}
+	var errs []error
+
+	unlink, err := link.Kprobe(UnlinkKprobedFunc, Objs.KprobeInodeUnlink, nil)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("attachTamperKprobes Error Open %s kprobe: %w", UnlinkKprobedFunc, err))
+	} else {
+		links = append(links, unlink)
+	}
+
+	rename, err := link.Kprobe(RenameKprobedFunc, Objs.KprobeInodeRename, nil)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("attachTamperKprobes Error Open %s kprobe: %w", RenameKprobedFunc, err))
+	} else {
+		links = append(links, rename)
+	}
+
+	// The function has an idmap argument since linux 6.0
+	params, err := kernelFuncParams(SetattrKprobedFunc)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("attachTamperKprobes Error: %w", err))
+		return links, errors.Join(errs...)
+	}
+	setattrProgram := Objs.KprobeInodeSetattrOld
+	if params > 2 {
+		setattrProgram = Objs.KprobeInodeSetattrNew
+	}
+	setattr, err := link.Kprobe(SetattrKprobedFunc, setattrProgram, nil)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("attachTamperKprobes Error Open %s kprobe: %w", SetattrKprobedFunc, err))
+	} else {
+		links = append(links, setattr)
+	}
+
+	return links, errors.Join(errs...)
+}
+
 /*
  *  Unload the eBPF program, objects and ringbuffer.
  */
 func UnloadEbpf(ctx context.Context) error {
 
-	if Kprobe != nil {
+	if PermissionLink != nil {
 
-		if err := Kprobe.Close(); err != nil {
+		if err := PermissionLink.Close(); err != nil {
 			return fmt.Errorf("UnloadEbpf Error Failed to close ebpf program: %w", err)
 		}
 
+		for _, tamperKprobe := range TamperKprobes {
+			if err := tamperKprobe.Close(); err != nil {
+				return fmt.Errorf("UnloadEbpf Error Failed to close ebpf program: %w", err)
+			}
+		}
+		TamperKprobes = nil
+
 		if err := Objs.TracedInodes.Close(); err != nil {
 			return fmt.Errorf("UnloadEbpf Error Failed to close eBPF map: %w", err)
 		}
@@ -112,6 +254,9 @@ func UnloadEbpf(ctx context.Context) error {
 			}
 		}
 
+		PermissionLink = nil
+		ActiveBackend = ""
+		metrics.EbpfBackend.Reset()
 		Loaded = false
 	}
 
@@ -121,16 +266,18 @@ func UnloadEbpf(ctx context.Context) error {
 /*
  *  Read the data from the Ringbuffer, hangs until data is received or
  *  returns an error. This function can be used without a running
- *  kubernetes cluster.
+ *  kubernetes cluster. If the Ringbuffer was closed, the returned
+ *  error wraps ringbuf.ErrClosed.
  */
-func ReadEbpfData() (bpfLogData, error) {
+func ReadEbpfData() (BpfLogData, error) {
 
 	var data bpfLogData
 	record, err := RingbuffReader.Read()
 	if err != nil {
 		if errors.Is(err, ringbuf.ErrClosed) {
-			return bpfLogData{}, fmt.Errorf("ReadAlert Error Buffer closed.")
+			return bpfLogData{}, fmt.Errorf("ReadAlert Error Buffer closed: %w", err)
 		}
+		return bpfLogData{}, fmt.Errorf("ReadAlert Error Read ringbuf: %w", err)
 	}
 
 	err = binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &data)
@@ -141,131 +288,130 @@ func ReadEbpfData() (bpfLogData, error) {
 	return data, nil
 }
 
-func ReadAlert(ctx context.Context, cli client.Reader) (kivev2alpha1.KiveAlert, error) {
+/*
+ *  Generate the synthetic KiveAlert sent to the sinks of the
+ *  KivePolicy of kiveData when lost eBPF events were not sent
+ *  because the ring buffer was full. Since the events are lost, It
+ *  has no file, pod or process.
+ */
+func GenerateLostAlert(kiveData kivev2alpha1.KiveData, lost uint64) kivev2alpha1.KiveAlert {
+
+	kiveAlertVersion := kiveData.Annotations["kive-alert-version"]
+	if !slices.Contains(kivev2alpha1.SupportedKiveAlertVersions, kiveAlertVersion) {
+		kiveAlertVersion = "v1"
+	}
 
-	if RingbuffReader == nil {
-		return kivev2alpha1.KiveAlert{}, fmt.Errorf("ReadAlert Error Ringbuffer not inizialized")
+	return kivev2alpha1.KiveAlert{
+		AlertVersion: kiveAlertVersion,
+		PolicyName:   kiveData.Annotations["kive-policy-name"],
+		Timestamp:    time.Now().Format(time.RFC3339),
+		Metadata: kivev2alpha1.KiveAlertMetadata{
+			KernelID: kiveData.ObjectMeta.Labels[comm.KernelIDLabel],
+		},
+		CustomMetadata: map[string]string{},
+		Node: kivev2alpha1.NodeMetadata{
+			Name: kiveData.Annotations["node-name"],
+		},
+		Lost: lost,
 	}
+}
+
+/*
+ *  Read what the eBPF program does not know about the process of an
+ *  access from the first of the procfs mountpoints that has It. The
+ *  paths read by the eBPF program take precedence over the procfs.
+ */
+func ReadEventProcess(ctx context.Context, procMountpoints []string, data BpfLogData) container.Process {
 
 	log := log.FromContext(ctx)
-	data, err := ReadEbpfData() // Hangs
+
+	// The eBPF program does not read the arguments, and leaves the
+	// paths that are too long empty. The process may have exited in
+	// the meantime, the alert is sent anyway.
+	process, err := container.ReadProcess(procMountpoints, data.Tgid, data.StartTime)
 	if err != nil {
-		return kivev2alpha1.KiveAlert{}, fmt.Errorf("ReadAlert Error Reading Ebpf Data: %w", err)
+		log.V(1).Info(fmt.Sprintf("Could not read the process %d while generating a KiveAlert, this can happen if the process terminated too quickly for the operator to react or the node is running in a container and the procfs of the host is not mounted in %s: %s",
+			data.Tgid, container.RealHostProcMountpoint, err))
 	}
 
-	kiveDataList := &kivev2alpha1.KiveDataList{}
-	err = cli.List(ctx, kiveDataList)
-	if err != nil {
-		return kivev2alpha1.KiveAlert{}, fmt.Errorf("ReadAlert Error Failed to get Kive Data resource: %w", err)
+	if executable := int8ArrayToPath(data.Exe[:]); executable != "" {
+		process.Executable = executable
+	}
+	if cwd := int8ArrayToPath(data.Cwd[:]); cwd != "" {
+		process.Cwd = cwd
 	}
 
-	for _, kiveData := range kiveDataList.Items {
-		if kiveData.Spec.InodeNo == data.Ino {
-
-			cwd := ""
-			args := ""
-			binary := ""
-
-			// If the node is a container on some host, then we need to read
-			// the host's procfs which is assumed to be mounted in
-			// /host/real/proc. If this does not exist, either the cluster
-			// is misconfigured or it is non containerized, then we check
-			// the regualr procfs of the node. This workaround is needed
-			// since procfs of a node is not the same as the host if the
-			// node is a container on the host (for example, for clusters
-			// created using Kind)
-			//
-			// TODO: this is a hack and has been disabled until a better
-			//       solution is ound
-			/*
-				cmdLine := ""
-				readSuccess := true
-				cwd, err = os.Readlink(fmt.Sprintf("%s/%d/cwd", container.RealHostProcMountpoint, data.Pid))
-				if err != nil {
-					cwd, err = os.Readlink(fmt.Sprintf("%s/%d/cwd", container.ProcMountpoint, data.Pid))
-					if err != nil {
-						readSuccess = false
-						// error is handled gracefully
-						log.Info(fmt.Sprintf("Could not read %s/%d/cwd while generating an KiveAlert, this can happen if the process terminated too quickly for the operator to react or the node is running in a container and procfs is not mounted in %s", container.ProcMountpoint, data.Pid, container.RealHostProcMountpoint))
-					}
-				}
-
-				if readSuccess {
-
-					cmdlinePath := fmt.Sprintf("%s/%d/cmdline", container.RealHostProcMountpoint, data.Pid)
-					cmdlineBytes, err := os.ReadFile(cmdlinePath)
-					if err != nil {
-						cmdlinePath = fmt.Sprintf("%s/%d/cmdline", container.ProcMountpoint, data.Pid)
-						cmdlineBytes, err = os.ReadFile(cmdlinePath)
-						if err != nil {
-							// error is handled gracefully
-							log.Info(fmt.Sprintf("Could not read %s/%d/cmdline while generating an KiveAlert, this can happen if the process terminated too quickly for the operator to react or the node is running in a container and procfs is not mounted in %s", container.ProcMountpoint, data.Pid, container.RealHostProcMountpoint))
-						}
-					}
-					cmdLine = string(cmdlineBytes)
-				}
-			*/
-
-			binary = int8ArrayToString(data.Comm[:])
-
-			// TODO: see above
-			/*
-				if cmdLine != "" {
-					binary, args = parseCmdline(cmdLine)
-				}
-			*/
-
-			kiveAlertVersion := kiveData.Annotations["kive-alert-version"]
-			if kiveAlertVersion == "" {
-				kiveAlertVersion = "v1"
-			} else if !slices.Contains(kivev2alpha1.SupportedKiveAlertVersions, kiveAlertVersion) {
-				log.Info(fmt.Sprintf("Generate KiveAlert for KivePolicy %s: version %s is not supported, defaulting to v1",
-					kiveData.Annotations["kive-policy-name"], kiveData.Annotations["version"]))
-				kiveAlertVersion = "v1"
-			}
+	return process
+}
 
-			out := kivev2alpha1.KiveAlert{
-				AlertVersion: kiveAlertVersion,
-				PolicyName:   kiveData.Annotations["kive-policy-name"],
-				Timestamp:    time.Now().Format(time.RFC3339),
-				Metadata: kivev2alpha1.KiveAlertMetadata{
-					Path:     kiveData.Annotations["path"],
-					Inode:    data.Ino,
-					Mask:     data.Mask,
-					KernelID: kiveData.ObjectMeta.Labels[comm.KernelIDLabel],
-					Callback: kiveData.ObjectMeta.Annotations["callback"],
-				},
-				CustomMetadata: map[string]string{},
-				Pod: kivev2alpha1.PodMetadata{
-					Name:      kiveData.Annotations["pod-name"],
-					Namespace: kiveData.Annotations["namespace"],
-					Container: kivev2alpha1.ContainerMetadata{
-						Id:   kiveData.Annotations["container-id"],
-						Name: kiveData.Annotations["container-name"],
-					},
-					Ip: kiveData.Annotations["ip"],
-				},
-				Node: kivev2alpha1.NodeMetadata{
-					Name: kiveData.Annotations["node-name"],
-				},
-				Process: kivev2alpha1.ProcessMetadata{
-					Pid:       data.Pid,
-					Tgid:      data.Tgid,
-					Uid:       data.Uid,
-					Gid:       data.Gid,
-					Binary:    binary,
-					Cwd:       cwd,
-					Arguments: args,
-				},
-			}
+/*
+ *  Generate the KiveAlert of an access reported by the eBPF program,
+ *  enriching It with the information of the KiveData of the trap It
+ *  matched and of process, read by ReadEventProcess.
+ */
+func GenerateAlert(ctx context.Context, kiveData kivev2alpha1.KiveData, process container.Process, data BpfLogData) kivev2alpha1.KiveAlert {
 
-			for key, val := range kiveData.Spec.Metadata {
-				out.CustomMetadata[key] = val
-			}
+	log := log.FromContext(ctx)
 
-			return out, nil
-		}
+	args := ""
+	if len(process.Args) > 1 {
+		args = strings.Join(process.Args[1:], " ")
+	}
+
+	kiveAlertVersion := kiveData.Annotations["kive-alert-version"]
+	if kiveAlertVersion == "" {
+		kiveAlertVersion = "v1"
+	} else if !slices.Contains(kivev2alpha1.SupportedKiveAlertVersions, kiveAlertVersion) {
+		log.Info(fmt.Sprintf("Generate KiveAlert for KivePolicy %s: version %s is not supported, defaulting to v1",
+			kiveData.Annotations["kive-policy-name"], kiveData.Annotations["version"]))
+		kiveAlertVersion = "v1"
+	}
+
+	out := kivev2alpha1.KiveAlert{
+		AlertVersion: kiveAlertVersion,
+		PolicyName:   kiveData.Annotations["kive-policy-name"],
+		Timestamp:    time.Now().Format(time.RFC3339),
+		Metadata: kivev2alpha1.KiveAlertMetadata{
+			Path:      kiveData.Annotations["path"],
+			Inode:     data.Ino,
+			Mask:      data.Mask,
+			Operation: operations[data.Operation],
+			KernelID:  kiveData.ObjectMeta.Labels[comm.KernelIDLabel],
+			Callback:  kiveData.ObjectMeta.Annotations["callback"],
+		},
+		CustomMetadata: map[string]string{},
+		Pod: kivev2alpha1.PodMetadata{
+			Name:      kiveData.Annotations["pod-name"],
+			Namespace: kiveData.Annotations["namespace"],
+			Container: kivev2alpha1.ContainerMetadata{
+				Id:   kiveData.Annotations["container-id"],
+				Name: kiveData.Annotations["container-name"],
+			},
+			Ip: kiveData.Annotations["ip"],
+		},
+		Node: kivev2alpha1.NodeMetadata{
+			Name: kiveData.Annotations["node-name"],
+		},
+		Process: kivev2alpha1.ProcessMetadata{
+			Pid:        data.Pid,
+			Tgid:       data.Tgid,
+			NsPid:      int32(data.NsPid),
+			NsTgid:     data.NsTgid,
+			Uid:        data.Uid,
+			Gid:        data.Gid,
+			Binary:     int8ArrayToString(data.Comm[:]),
+			Executable: process.Executable,
+			Cwd:        process.Cwd,
+			Arguments:  args,
+		},
+		Suppressed: data.Suppressed,
+		Blocked:    data.Blocked == uint32(DenyEnforce),
+		WouldBlock: data.Blocked == uint32(DenyDryRun),
+	}
+
+	for key, val := range kiveData.Spec.Metadata {
+		out.CustomMetadata[key] = val
 	}
 
-	return kivev2alpha1.KiveAlert{}, fmt.Errorf("ReadAlert Error eBPF data received but no corresponsing kiveData was found")
+	return out
 }
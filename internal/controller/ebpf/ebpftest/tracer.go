@@ -0,0 +1,287 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+// Test helpers of the ebpf package, which are not built into the
+// operator
+package ebpftest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/cilium/ebpf/ringbuf"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+	ebpf "github.com/San7o/kivebpf/internal/controller/ebpf"
+)
+
+// In-memory ebpf.Tracer that loads nothing in the kernel. The maps
+// are kept in memory and the accesses are the events sent with Emit,
+// so the reconcilers and the alert pipeline can be tested without
+// privileges.
+type Tracer struct {
+	// Backend reported by the tracer, ebpf.BackendLSM if empty
+	FakeBackend ebpf.Backend
+	// Entries of the inode map, ebpf.MapMaxEntries if 0
+	MaxEntries int
+
+	mutex      sync.Mutex
+	loaded     bool
+	inodes     map[ebpf.BpfMapKey]uint8
+	allowlists map[ebpf.BpfMapKey]*kivev2alpha1.KiveTrapAllow
+	denied     map[ebpf.BpfMapKey]uint8
+	lost       uint64
+	events     chan ebpf.BpfLogData
+	done       chan struct{}
+}
+
+var _ ebpf.Tracer = &Tracer{}
+
+func NewTracer() *Tracer {
+	return &Tracer{
+		inodes:     map[ebpf.BpfMapKey]uint8{},
+		allowlists: map[ebpf.BpfMapKey]*kivev2alpha1.KiveTrapAllow{},
+		denied:     map[ebpf.BpfMapKey]uint8{},
+		events:     make(chan ebpf.BpfLogData, 1024),
+		done:       make(chan struct{}),
+	}
+}
+
+func (self *Tracer) Load(ctx context.Context) error {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.loaded = true
+
+	return nil
+}
+
+func (self *Tracer) IsLoaded() bool {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	return self.loaded
+}
+
+func (self *Tracer) Backend() ebpf.Backend {
+	return self.backend()
+}
+
+func (self *Tracer) backend() ebpf.Backend {
+
+	if self.FakeBackend == "" {
+		return ebpf.BackendLSM
+	}
+
+	return self.FakeBackend
+}
+
+func (self *Tracer) AddInode(mapKey ebpf.BpfMapKey, filter uint8) error {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	maxEntries := self.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = ebpf.MapMaxEntries
+	}
+	if _, ok := self.inodes[mapKey]; !ok && len(self.inodes) >= maxEntries {
+		// Like the kernel, IsMapFull reports It
+		return fmt.Errorf("AddInode Error: %w", syscall.E2BIG)
+	}
+	self.inodes[mapKey] = filter
+
+	return nil
+}
+
+func (self *Tracer) RemoveInode(mapKey ebpf.BpfMapKey) error {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if _, ok := self.inodes[mapKey]; !ok {
+		return fmt.Errorf("RemoveInode Error: %w", syscall.ENOENT)
+	}
+	delete(self.inodes, mapKey)
+
+	return nil
+}
+
+func (self *Tracer) SetAllowlist(mapKey ebpf.BpfMapKey, allow *kivev2alpha1.KiveTrapAllow) error {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if allow == nil {
+		delete(self.allowlists, mapKey)
+		return nil
+	}
+	self.allowlists[mapKey] = allow.DeepCopy()
+
+	return nil
+}
+
+func (self *Tracer) RemoveAllowlist(mapKey ebpf.BpfMapKey) error {
+	return self.SetAllowlist(mapKey, nil)
+}
+
+func (self *Tracer) SetDeny(mapKey ebpf.BpfMapKey, action uint8) error {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if action == 0 {
+		delete(self.denied, mapKey)
+		return nil
+	}
+	if action == ebpf.DenyEnforce && self.backend() != ebpf.BackendLSM {
+		return fmt.Errorf("SetDeny Error backend %s: %w", self.backend(), ebpf.ErrDenyNotSupported)
+	}
+
+	self.denied[mapKey] = action
+
+	return nil
+}
+
+func (self *Tracer) CountInodes() (int, error) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	return len(self.inodes), nil
+}
+
+func (self *Tracer) CountLostEvents() (uint64, error) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	return self.lost, nil
+}
+
+func (self *Tracer) ReadEvent() (ebpf.BpfLogData, error) {
+
+	select {
+	case data := <-self.events:
+		return data, nil
+	case <-self.done:
+		return ebpf.BpfLogData{}, fmt.Errorf("ReadEvent Error Buffer closed: %w", ringbuf.ErrClosed)
+	}
+}
+
+func (self *Tracer) Unload(ctx context.Context) error {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.loaded {
+		close(self.done)
+		self.loaded = false
+	}
+
+	return nil
+}
+
+/*
+ *  Send a synthetic access to the readers of the tracer, as if the
+ *  eBPF program reported It. Like the eBPF program, only the
+ *  accesses to the traced inodes are sent, the others are ignored
+ *  and false is returned.
+ */
+func (self *Tracer) Emit(data ebpf.BpfLogData) bool {
+
+	self.mutex.Lock()
+	_, traced := self.inodes[ebpf.BpfMapKey{Inode: data.Ino, Dev: data.Dev}]
+	self.mutex.Unlock()
+	if !traced {
+		return false
+	}
+
+	select {
+	case self.events <- data:
+		return true
+	case <-self.done:
+		return false
+	}
+}
+
+/*
+ *  Count lost events, as if the ring buffer was full
+ */
+func (self *Tracer) AddLost(lost uint64) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.lost += lost
+}
+
+/*
+ *  The filter of the traced inode, false if It is not traced
+ */
+func (self *Tracer) Filter(mapKey ebpf.BpfMapKey) (uint8, bool) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	filter, ok := self.inodes[mapKey]
+	return filter, ok
+}
+
+/*
+ *  The allowlist of the inode, nil if It has none
+ */
+func (self *Tracer) Allowlist(mapKey ebpf.BpfMapKey) *kivev2alpha1.KiveTrapAllow {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	return self.allowlists[mapKey]
+}
+
+/*
+ *  The deny action of the inode, 0 if Its accesses are not denied
+ */
+func (self *Tracer) DenyAction(mapKey ebpf.BpfMapKey) uint8 {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	return self.denied[mapKey]
+}
+
+/*
+ *  Encode path like the eBPF program writes the paths of the
+ *  process in ebpf.BpfLogData, from the last name up to the root
+ */
+func Path(path string) [256]int8 {
+
+	names := []string{"/"}
+	for _, name := range strings.Split(path, "/") {
+		if name != "" {
+			names = slices.Insert(names, 0, name)
+		}
+	}
+
+	var out [256]int8
+	for i, c := range []byte(strings.Join(names, "\x00")) {
+		out[i] = int8(c)
+	}
+
+	return out
+}
@@ -0,0 +1,54 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package ebpftest
+
+import (
+	"errors"
+	"testing"
+
+	ebpf "github.com/San7o/kivebpf/internal/controller/ebpf"
+)
+
+func TestTracerSetDeny(t *testing.T) {
+
+	mapKey := ebpf.BpfMapKey{Inode: 1, Dev: 2}
+
+	tests := []struct {
+		name    string
+		backend ebpf.Backend
+		action  uint8
+		wantErr error
+		want    uint8
+	}{
+		{"enforce", ebpf.BackendLSM, ebpf.DenyEnforce, nil, ebpf.DenyEnforce},
+		{"dry run", ebpf.BackendKprobe, ebpf.DenyDryRun, nil, ebpf.DenyDryRun},
+		// Like the kernel tracer, nothing is stored
+		{"enforce not supported", ebpf.BackendKprobe, ebpf.DenyEnforce, ebpf.ErrDenyNotSupported, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			tracer := NewTracer()
+			tracer.FakeBackend = test.backend
+
+			err := tracer.SetDeny(mapKey, test.action)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("SetDeny = %v, want %v", err, test.wantErr)
+			}
+			if got := tracer.DenyAction(mapKey); got != test.want {
+				t.Errorf("deny action %d, want %d", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package ebpf
+
+import (
+	"sort"
+	"sync"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+// Local index of the traced files, used to find the KiveData of an
+// eBPF event without querying the API server. It is kept in sync
+// with the eBPF map by the KiveData reconciler. A file trapped by
+// several traps has one KiveData for each of them, indexed by name.
+type InodeIndex struct {
+	mutex sync.RWMutex
+	data  map[BpfMapKey]map[string]kivev2alpha1.KiveData
+}
+
+func NewInodeIndex() *InodeIndex {
+	return &InodeIndex{
+		data: map[BpfMapKey]map[string]kivev2alpha1.KiveData{},
+	}
+}
+
+func (self *InodeIndex) Set(kiveData kivev2alpha1.KiveData) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	mapKey := KiveDataMapKey(kiveData)
+	if self.data[mapKey] == nil {
+		self.data[mapKey] = map[string]kivev2alpha1.KiveData{}
+	}
+	self.data[mapKey][kiveData.Name] = *kiveData.DeepCopy()
+}
+
+/*
+ *  The KiveData of the traced file mapKey, sorted by name. It is
+ *  empty if the file is not traced.
+ */
+func (self *InodeIndex) Get(mapKey BpfMapKey) []kivev2alpha1.KiveData {
+
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	kiveDatas := make([]kivev2alpha1.KiveData, 0, len(self.data[mapKey]))
+	for _, kiveData := range self.data[mapKey] {
+		kiveDatas = append(kiveDatas, kiveData)
+	}
+	sort.Slice(kiveDatas, func(i, j int) bool {
+		return kiveDatas[i].Name < kiveDatas[j].Name
+	})
+
+	return kiveDatas
+}
+
+func (self *InodeIndex) Delete(kiveData kivev2alpha1.KiveData) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	mapKey := KiveDataMapKey(kiveData)
+	delete(self.data[mapKey], kiveData.Name)
+	if len(self.data[mapKey]) == 0 {
+		delete(self.data, mapKey)
+	}
+}
+
+/*
+ *  Remove every KiveData whose name is not in keep. Used to drop
+ *  the KiveData that disappeared without going through their
+ *  finalizer.
+ */
+func (self *InodeIndex) Retain(keep map[string]bool) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	for mapKey, kiveDatas := range self.data {
+		for name := range kiveDatas {
+			if !keep[name] {
+				delete(kiveDatas, name)
+			}
+		}
+		if len(kiveDatas) == 0 {
+			delete(self.data, mapKey)
+		}
+	}
+}
+
+func (self *InodeIndex) List() []kivev2alpha1.KiveData {
+
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	kiveDatas := []kivev2alpha1.KiveData{}
+	for _, byName := range self.data {
+		for _, kiveData := range byName {
+			kiveDatas = append(kiveDatas, kiveData)
+		}
+	}
+
+	return kiveDatas
+}
+
+// Number of KiveData in the index
+func (self *InodeIndex) Len() int {
+
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	length := 0
+	for _, kiveDatas := range self.data {
+		length += len(kiveDatas)
+	}
+
+	return length
+}
+
+func KiveDataMapKey(kiveData kivev2alpha1.KiveData) BpfMapKey {
+	return BpfMapKey{Inode: kiveData.Spec.InodeNo, Dev: kiveData.Spec.DevID}
+}
@@ -0,0 +1,60 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package ebpf
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+func testKiveData(name string, inode uint64) kivev2alpha1.KiveData {
+	return kivev2alpha1.KiveData{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       kivev2alpha1.KiveDataSpec{InodeNo: inode, DevID: 1},
+	}
+}
+
+func TestInodeIndexOverlappingTraps(t *testing.T) {
+
+	index := NewInodeIndex()
+	index.Set(testKiveData("shadow-b", 1))
+	index.Set(testKiveData("shadow-a", 1))
+	index.Set(testKiveData("passwd", 2))
+	// Updated in place
+	index.Set(testKiveData("shadow-a", 1))
+
+	if index.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", index.Len())
+	}
+	kiveDatas := index.Get(BpfMapKey{Inode: 1, Dev: 1})
+	if len(kiveDatas) != 2 || kiveDatas[0].Name != "shadow-a" || kiveDatas[1].Name != "shadow-b" {
+		t.Fatalf("Get = %v, want shadow-a and shadow-b", kiveDatas)
+	}
+
+	index.Delete(testKiveData("shadow-a", 1))
+	kiveDatas = index.Get(BpfMapKey{Inode: 1, Dev: 1})
+	if len(kiveDatas) != 1 || kiveDatas[0].Name != "shadow-b" {
+		t.Fatalf("Get after Delete = %v, want shadow-b", kiveDatas)
+	}
+
+	index.Retain(map[string]bool{"passwd": true})
+	if kiveDatas := index.Get(BpfMapKey{Inode: 1, Dev: 1}); len(kiveDatas) != 0 {
+		t.Fatalf("Get after Retain = %v, want none", kiveDatas)
+	}
+	if index.Len() != 1 || len(index.List()) != 1 {
+		t.Fatalf("Len after Retain = %d, want 1", index.Len())
+	}
+}
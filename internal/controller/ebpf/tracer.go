@@ -0,0 +1,113 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package ebpf
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cilium/ebpf/ringbuf"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+// A Tracer arms the traps in the kernel and reads the accesses to
+// the trapped inodes. The reconcilers and the alert pipeline use It
+// instead of the eBPF maps and the ring buffer, so that they can run
+// without a kernel.
+type Tracer interface {
+	// Load the programs and the maps, the traps are armed only
+	// after that
+	Load(ctx context.Context) error
+	IsLoaded() bool
+	// The backend of the loaded programs
+	Backend() Backend
+	AddInode(mapKey BpfMapKey, filter uint8) error
+	RemoveInode(mapKey BpfMapKey) error
+	SetAllowlist(mapKey BpfMapKey, allow *kivev2alpha1.KiveTrapAllow) error
+	RemoveAllowlist(mapKey BpfMapKey) error
+	SetDeny(mapKey BpfMapKey, action uint8) error
+	CountInodes() (int, error)
+	CountLostEvents() (uint64, error)
+	// Hangs until an access is read. Once the Tracer is unloaded, the
+	// returned error wraps ringbuf.ErrClosed
+	ReadEvent() (BpfLogData, error)
+	Unload(ctx context.Context) error
+}
+
+var (
+	// The Tracer used when none is given, It loads the eBPF programs
+	// in the kernel
+	DefaultTracer Tracer = &KernelTracer{}
+)
+
+// Tracer of the eBPF programs and maps of this package
+type KernelTracer struct{}
+
+func (self *KernelTracer) Load(ctx context.Context) error {
+	return LoadEbpf(ctx)
+}
+
+func (self *KernelTracer) IsLoaded() bool {
+	return Loaded
+}
+
+func (self *KernelTracer) Backend() Backend {
+	return ActiveBackend
+}
+
+func (self *KernelTracer) AddInode(mapKey BpfMapKey, filter uint8) error {
+	return AddInode(mapKey, filter)
+}
+
+func (self *KernelTracer) RemoveInode(mapKey BpfMapKey) error {
+	return RemoveInode(mapKey)
+}
+
+func (self *KernelTracer) SetAllowlist(mapKey BpfMapKey, allow *kivev2alpha1.KiveTrapAllow) error {
+	return SetAllowlist(mapKey, allow)
+}
+
+func (self *KernelTracer) RemoveAllowlist(mapKey BpfMapKey) error {
+	return RemoveAllowlist(mapKey)
+}
+
+func (self *KernelTracer) SetDeny(mapKey BpfMapKey, action uint8) error {
+	return SetDeny(mapKey, action)
+}
+
+func (self *KernelTracer) CountInodes() (int, error) {
+	return CountInodes()
+}
+
+func (self *KernelTracer) CountLostEvents() (uint64, error) {
+
+	if Objs.LostEvents == nil {
+		return 0, nil
+	}
+
+	return CountLostEvents()
+}
+
+func (self *KernelTracer) ReadEvent() (BpfLogData, error) {
+
+	if RingbuffReader == nil {
+		return BpfLogData{}, fmt.Errorf("ReadEvent Error Ringbuffer not inizialized: %w", ringbuf.ErrClosed)
+	}
+
+	return ReadEbpfData() // Hangs
+}
+
+func (self *KernelTracer) Unload(ctx context.Context) error {
+	return UnloadEbpf(ctx)
+}
@@ -13,21 +13,25 @@
 package ebpf
 
 import (
+	"errors"
 	"fmt"
-	"strconv"
+	"slices"
 	"strings"
 	"syscall"
 
 	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
 )
 
 /*
- *  Add an entry to the map
+ *  Add an entry to the map, or update Its filter. Only the accesses
+ *  matching filter, made of the Access* bits, are alerted.
  */
-func AddInode(mapKey BpfMapKey) error {
+func AddInode(mapKey BpfMapKey, filter uint8) error {
 
-	one := uint8(1)
-	err := Objs.TracedInodes.Update(mapKey, one, ebpf.UpdateAny)
+	err := Objs.TracedInodes.Update(mapKey, filter, ebpf.UpdateAny)
 	if err != nil {
 		return fmt.Errorf("AddInode Error: %w", err)
 	}
@@ -35,6 +39,92 @@ func AddInode(mapKey BpfMapKey) error {
 	return nil
 }
 
+/*
+ *  Filter of the traced_inodes map for the kinds of access, any
+ *  access if empty
+ */
+func AccessFilter(access []kivev2alpha1.KiveAccess) (uint8, error) {
+
+	if len(access) == 0 {
+		return AccessAll, nil
+	}
+
+	filter := uint8(0)
+	for _, kind := range access {
+		bit, ok := accessBits[kind]
+		if !ok {
+			return 0, fmt.Errorf("AccessFilter Error: access %s is not supported", kind)
+		}
+		filter |= bit
+	}
+
+	return filter, nil
+}
+
+/*
+ *  Whether an eBPF event matches the filter and the allowlist of a
+ *  trap, the same way as the eBPF program. When traps overlap on a
+ *  file, the eBPF program alerts on the union of their filters and
+ *  ignores only the processes allowed by all of them, so the events
+ *  are matched again against each trap. The tampering operations
+ *  are not filtered by the kind of access.
+ */
+func EventMatches(data BpfLogData, filter uint8, allow *kivev2alpha1.KiveTrapAllow) bool {
+
+	if data.Operation == opPermission && !accessMatches(data.Mask, filter) {
+		return false
+	}
+
+	return !isAllowed(allow, data)
+}
+
+/*
+ *  Whether an access with mask should be alerted according to the
+ *  filter of Its traced inode
+ */
+func accessMatches(mask int32, filter uint8) bool {
+
+	if filter == AccessAll {
+		return true
+	}
+
+	// access(2) checks are alerted only if requested
+	if mask&int32(AccessAccess) != 0 && filter&AccessAccess == 0 {
+		return false
+	}
+
+	return mask&int32(filter) != 0
+}
+
+/*
+ *  Set the action applied to the accesses to the inode, DenyEnforce
+ *  or DenyDryRun, or remove It if action is 0. The accesses are
+ *  denied only by the lsm backend, with the others DenyEnforce is
+ *  not stored, ErrDenyNotSupported is returned and the accesses are
+ *  only alerted.
+ */
+func SetDeny(mapKey BpfMapKey, action uint8) error {
+
+	if action == 0 {
+		err := Objs.DeniedInodes.Delete(mapKey)
+		if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return fmt.Errorf("SetDeny Error Delete: %w", err)
+		}
+		return nil
+	}
+
+	if action == DenyEnforce && ActiveBackend != BackendLSM {
+		return fmt.Errorf("SetDeny Error backend %s: %w", ActiveBackend, ErrDenyNotSupported)
+	}
+
+	err := Objs.DeniedInodes.Update(mapKey, action, ebpf.UpdateAny)
+	if err != nil {
+		return fmt.Errorf("SetDeny Error Update: %w", err)
+	}
+
+	return nil
+}
+
 /*
  *  Remove an entry from the map
  */
@@ -48,6 +138,53 @@ func RemoveInode(mapKey BpfMapKey) error {
 	return nil
 }
 
+/*
+ *  Count the entries in the map
+ */
+func CountInodes() (int, error) {
+
+	var (
+		mapKey BpfMapKey
+		value  uint8
+	)
+	count := 0
+	entries := Objs.TracedInodes.Iterate()
+	for entries.Next(&mapKey, &value) {
+		count++
+	}
+	if err := entries.Err(); err != nil {
+		return 0, fmt.Errorf("CountInodes Error: %w", err)
+	}
+
+	return count, nil
+}
+
+/*
+ *  Number of events the eBPF program could not send because the ring
+ *  buffer was full, summed over all the CPUs
+ */
+func CountLostEvents() (uint64, error) {
+
+	var perCPU []uint64
+	if err := Objs.LostEvents.Lookup(uint32(0), &perCPU); err != nil {
+		return 0, fmt.Errorf("CountLostEvents Error: %w", err)
+	}
+
+	lost := uint64(0)
+	for _, count := range perCPU {
+		lost += count
+	}
+
+	return lost, nil
+}
+
+/*
+ *  Whether err was returned because the map has no free entries
+ */
+func IsMapFull(err error) bool {
+	return errors.Is(err, syscall.E2BIG)
+}
+
 func int8ArrayToString(arr []int8) string {
 	b := make([]byte, 0, len(arr))
 	for _, c := range arr {
@@ -59,52 +196,53 @@ func int8ArrayToString(arr []int8) string {
 	return string(b)
 }
 
-func parseCmdline(cmdline string) (binary string, args string) {
-
-	parts := strings.Split(cmdline, "\x00")
+/*
+ *  Path written by the eBPF program, as the names from the last one
+ *  up to the root "/", each one followed by a NUL. If the program
+ *  could not read It, the path is empty.
+ */
+func int8ArrayToPath(arr []int8) string {
 
-	cleaned := make([]string, 0, len(parts))
-	for _, part := range parts {
-		if part != "" {
-			cleaned = append(cleaned, part)
+	names := []string{}
+	start := 0
+	for i, c := range arr {
+		if c != 0 {
+			continue
 		}
+		name := int8ArrayToString(arr[start:i])
+		switch name {
+		case "":
+			return ""
+		case "/":
+			slices.Reverse(names)
+			return "/" + strings.Join(names, "/")
+		}
+		names = append(names, name)
+		start = i + 1
 	}
 
-	if len(cleaned) == 0 {
-		return "", ""
-	}
-
-	binary = cleaned[0]
-	if len(cleaned) > 1 {
-		args = strings.Join(cleaned[1:], " ")
-	} else {
-		args = ""
-	}
-
-	return binary, args
+	return ""
 }
 
 /*
- *  The function check_permission was hanged in linux 5.12, hence
- *  we need to load a different program if the version is newer or
- *  older than that.
+ *  Number of parameters of a kernel function, read from the BTF of
+ *  the kernel.
  */
-func isKernelOld() (bool, error) {
+func kernelFuncParams(name string) (int, error) {
 
-	var uname syscall.Utsname
-	if err := syscall.Uname(&uname); err != nil {
-		return false, fmt.Errorf("isKernelOld Error Syscall Uname: %w", err)
+	spec, err := btf.LoadKernelSpec()
+	if err != nil {
+		return 0, fmt.Errorf("kernelFuncParams Error Load kernel BTF: %w", err)
 	}
 
-	release := int8ArrayToString(uname.Release[:])
-	major, err := strconv.Atoi(strings.Split(release, ".")[0])
-	if err != nil {
-		return false, fmt.Errorf("isKernelOld Error Conversion to integer of major kernel version: %w", err)
+	var function *btf.Func
+	if err := spec.TypeByName(name, &function); err != nil {
+		return 0, fmt.Errorf("kernelFuncParams Error Find %s: %w", name, err)
 	}
-	minor, err := strconv.Atoi(strings.Split(release, ".")[1])
-	if err != nil {
-		return false, fmt.Errorf("isKernelOld Error Conversion to integer of minor kernel version: %w", err)
+	proto, ok := function.Type.(*btf.FuncProto)
+	if !ok {
+		return 0, fmt.Errorf("kernelFuncParams Error %s has no prototype", name)
 	}
 
-	return major < 5 || (major == 5 && minor <= 11), nil
+	return len(proto.Params), nil
 }
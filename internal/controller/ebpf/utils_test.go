@@ -0,0 +1,84 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package ebpf
+
+import (
+	"testing"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+func TestInt8ArrayToPath(t *testing.T) {
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"root", "/\x00", "/"},
+		{"file", "cat\x00bin\x00usr\x00/\x00", "/usr/bin/cat"},
+		// Written by the eBPF program when the path does not fit
+		{"empty", "", ""},
+		{"not read", "\x00bin\x00usr\x00/\x00", ""},
+		{"no root", "cat\x00bin\x00usr\x00", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			arr := make([]int8, 256)
+			for i, c := range []byte(test.data) {
+				arr[i] = int8(c)
+			}
+
+			if got := int8ArrayToPath(arr); got != test.want {
+				t.Errorf("int8ArrayToPath = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEventMatches(t *testing.T) {
+
+	comm := [16]int8{}
+	for i, c := range "passwd" {
+		comm[i] = int8(c)
+	}
+	allow := &kivev2alpha1.KiveTrapAllow{Binaries: []string{"passwd"}, Uids: []uint32{1000}}
+
+	tests := []struct {
+		name   string
+		data   bpfLogData
+		filter uint8
+		allow  *kivev2alpha1.KiveTrapAllow
+		want   bool
+	}{
+		{"any access", bpfLogData{Mask: int32(AccessWrite)}, AccessAll, nil, true},
+		{"filtered", bpfLogData{Mask: int32(AccessRead)}, AccessRead, nil, true},
+		{"filtered out", bpfLogData{Mask: int32(AccessWrite)}, AccessRead, nil, false},
+		{"access(2) not requested", bpfLogData{Mask: int32(AccessRead | AccessAccess)}, AccessRead, nil, false},
+		{"access(2) requested", bpfLogData{Mask: int32(AccessRead | AccessAccess)}, AccessAccess, nil, true},
+		{"tampering", bpfLogData{Operation: opUnlink}, AccessRead, nil, true},
+		{"allowed binary", bpfLogData{Mask: int32(AccessRead), Comm: comm}, AccessAll, allow, false},
+		{"allowed uid", bpfLogData{Mask: int32(AccessRead), Uid: 1000}, AccessAll, allow, false},
+		{"not allowed", bpfLogData{Mask: int32(AccessRead), Uid: 1001}, AccessAll, allow, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := EventMatches(test.data, test.filter, test.allow); got != test.want {
+				t.Errorf("EventMatches = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	DefaultBurst    = 5
+	DefaultInterval = time.Minute
+
+	// Maximum number of objects and reasons whose rate is tracked
+	maxLimiters = 4096
+
+	// Reasons of the Events
+	FileAccessedReason     = "FileAccessed"
+	FileTamperedReason     = "FileTampered"
+	FileAccessDeniedReason = "FileAccessDenied"
+	TrapPathNotFoundReason = "TrapPathNotFound"
+	TrapNotArmedReason     = "TrapNotArmed"
+	TrapFileReplacedReason = "TrapFileReplaced"
+	TrapNotEnforcedReason  = "TrapNotEnforced"
+	InodeMapFullReason     = "InodeMapFull"
+	EventsLostReason       = "EventsLost"
+)
+
+type limiterKey struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Reason    string
+}
+
+// A Recorder records Kubernetes Events. For each object and reason,
+// a burst of Burst Events is recorded and then one Event every
+// Interval, the others are dropped. A nil Recorder records nothing.
+type Recorder struct {
+	recorder record.EventRecorder
+	Burst    int
+	Interval time.Duration
+
+	mutex    sync.Mutex
+	limiters map[limiterKey]*rate.Limiter
+}
+
+func NewRecorder(recorder record.EventRecorder, burst int, interval time.Duration) *Recorder {
+
+	if burst <= 0 {
+		burst = DefaultBurst
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return &Recorder{
+		recorder: recorder,
+		Burst:    burst,
+		Interval: interval,
+		limiters: map[limiterKey]*rate.Limiter{},
+	}
+}
+
+/*
+ *  Record an Event on object, which can also be an
+ *  ObjectReference, if the rate of Its reason allows It.
+ */
+func (self *Recorder) Eventf(object runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+
+	if self == nil || object == nil {
+		return
+	}
+	if ref, ok := object.(*corev1.ObjectReference); ok && ref == nil {
+		return
+	}
+
+	if !self.allow(object, reason) {
+		return
+	}
+
+	self.recorder.Eventf(object, eventType, reason, messageFmt, args...)
+}
+
+func (self *Recorder) allow(object runtime.Object, reason string) bool {
+
+	key := limiterKey{Reason: reason}
+	if ref, ok := object.(*corev1.ObjectReference); ok {
+		key.Kind, key.Namespace, key.Name = ref.Kind, ref.Namespace, ref.Name
+	} else if accessor, err := meta.Accessor(object); err == nil {
+		// Typed objects read from the API often have an empty kind
+		key.Kind = fmt.Sprintf("%T", object)
+		key.Namespace, key.Name = accessor.GetNamespace(), accessor.GetName()
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	limiter, ok := self.limiters[key]
+	if !ok {
+		if len(self.limiters) >= maxLimiters {
+			self.forgetIdle()
+		}
+		limiter = rate.NewLimiter(rate.Every(self.Interval), self.Burst)
+		self.limiters[key] = limiter
+	}
+
+	return limiter.Allow()
+}
+
+// Forget the limiters whose bucket is full, they behave like new
+// ones. If all of them are in use, forget all of them.
+func (self *Recorder) forgetIdle() {
+
+	for key, limiter := range self.limiters {
+		if limiter.Tokens() >= float64(self.Burst) {
+			delete(self.limiters, key)
+		}
+	}
+	if len(self.limiters) >= maxLimiters {
+		self.limiters = map[limiterKey]*rate.Limiter{}
+	}
+}
+
+/*
+ *  Reference to a Pod that can be recorded without fetching It. An
+ *  empty uid does not match the Events shown by kubectl describe.
+ */
+func PodReference(namespace, name, uid string) *corev1.ObjectReference {
+
+	if name == "" {
+		return nil
+	}
+
+	return &corev1.ObjectReference{
+		Kind:       "Pod",
+		APIVersion: "v1",
+		Namespace:  namespace,
+		Name:       name,
+		UID:        types.UID(uid),
+	}
+}
+
+/*
+ *  Reference to a KivePolicy that can be recorded without fetching
+ *  It.
+ */
+func KivePolicyReference(namespace, name, uid string) *corev1.ObjectReference {
+
+	if name == "" {
+		return nil
+	}
+
+	return &corev1.ObjectReference{
+		Kind:       "KivePolicy",
+		APIVersion: kivev2alpha1.GroupVersion.String(),
+		Namespace:  namespace,
+		Name:       name,
+		UID:        types.UID(uid),
+	}
+}
@@ -14,25 +14,47 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logger "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
 	comm "github.com/San7o/kivebpf/internal/controller/comm"
 	ebpf "github.com/San7o/kivebpf/internal/controller/ebpf"
+	events "github.com/San7o/kivebpf/internal/controller/events"
+	metrics "github.com/San7o/kivebpf/internal/controller/metrics"
 )
 
 type KiveDataReconciler struct {
 	client.Client
 	UncachedClient client.Reader
 	Scheme         *runtime.Scheme
+	// Generates and delivers the alerts once the eBPF program is
+	// loaded. If nil, a pipeline with the default options is used
+	Output *AlertPipeline
+	// (optional) Records Events when a trap cannot be armed
+	Recorder *events.Recorder
+	// Only report the accesses that the traps with the deny action
+	// would deny, as if all of them were in dry-run mode
+	DenyDryRun bool
+	// Arms the traps and reads the accesses. If nil,
+	// ebpf.DefaultTracer is used
+	Tracer ebpf.Tracer
+	// Index of the armed KiveData, shared with the Output. If nil,
+	// ebpf.DefaultIndex is used
+	Index *ebpf.InodeIndex
 }
 
 const (
@@ -43,17 +65,34 @@ const (
 // +kubebuilder:rbac:groups=kivebpf.san7o.github.io,resources=kivedata/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kivebpf.san7o.github.io,resources=kivedata/finalizers,verbs=update
 
+// Secrets referenced by the sinks to authenticate the alerts
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get
+
+// KiveAlertRecords stored by the record sinks
+// +kubebuilder:rbac:groups=kivebpf.san7o.github.io,resources=kivealertrecords,verbs=get;list;watch;create;delete
+
 func (r *KiveDataReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
 	log := logger.FromContext(ctx)
 	log.Info("KiveData reconcile triggered.")
 
-	if !ebpf.Loaded {
+	tracer := r.tracer()
+	if !tracer.IsLoaded() {
 		log.Info("Loading eBPF program")
-		if err := ebpf.LoadEbpf(ctx); err != nil { // Fatal
+		if err := tracer.Load(ctx); err != nil { // Fatal
 			return ctrl.Result{}, fmt.Errorf("Reconcile Error Load eBPF program: %w", err)
 		}
-		go Output(r.UncachedClient)
+		output := r.Output
+		if output == nil {
+			output = &AlertPipeline{}
+		}
+		if output.Tracer == nil {
+			output.Tracer = tracer
+		}
+		if output.Index == nil {
+			output.Index = r.index()
+		}
+		go output.Run(context.Background())
 	}
 
 	kiveDataLabels := client.MatchingLabels{
@@ -71,6 +110,11 @@ func (r *KiveDataReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, fmt.Errorf("Reconcile Error Failed to get KivePolicy resource: %w", err)
 	}
 
+	// The KiveData to arm, grouped by file since the traps that
+	// overlap on a file share Its entries in the eBPF maps
+	armed := map[ebpf.BpfMapKey][]kivev2alpha1.KiveData{}
+	armedKeys := []ebpf.BpfMapKey{}
+
 	// Check if each KiveData (referring to this kernel id) does have a
 	// corresponding KivePolicy. If it does, then we update the eBPF
 	// map with the information from the KiveData. If it doesn't, then
@@ -86,10 +130,24 @@ Data:
 
 				kiveDataCopy := kiveData.DeepCopy()
 
-				err := ebpf.RemoveInode(ebpf.BpfMapKey{Inode: kiveData.Spec.InodeNo, Dev: kiveData.Spec.DevID})
-				if err != nil {
-					log.Info("Reconcile Error Remove Inode during deletion of KiveData %s: %w", kiveData.Name, err)
+				// The file stays traced if other traps still have a
+				// KiveData on It, the next reconciliation updates Its
+				// maps without this one
+				if !sharesFile(kiveDataList.Items, kiveData) {
+					err := tracer.RemoveInode(ebpf.KiveDataMapKey(kiveData))
+					if err != nil {
+						log.Info("Reconcile Error Remove Inode during deletion of KiveData %s: %w", kiveData.Name, err)
+					}
+					err = tracer.RemoveAllowlist(ebpf.KiveDataMapKey(kiveData))
+					if err != nil {
+						log.Error(err, fmt.Sprintf("Reconcile Error Remove allowlist during deletion of KiveData %s", kiveData.Name))
+					}
+					err = tracer.SetDeny(ebpf.KiveDataMapKey(kiveData), 0)
+					if err != nil {
+						log.Error(err, fmt.Sprintf("Reconcile Error Remove deny action during deletion of KiveData %s", kiveData.Name))
+					}
 				}
+				r.index().Delete(kiveData)
 
 				controllerutil.RemoveFinalizer(kiveDataCopy, KiveDataFinalizerName)
 
@@ -185,16 +243,130 @@ Data:
 			continue Data
 		}
 
-		err = ebpf.AddInode(ebpf.BpfMapKey{Inode: kiveData.Spec.InodeNo, Dev: kiveData.Spec.DevID})
+		mapKey := ebpf.KiveDataMapKey(kiveData)
+		if _, ok := armed[mapKey]; !ok {
+			armedKeys = append(armedKeys, mapKey)
+		}
+		armed[mapKey] = append(armed[mapKey], kiveData)
+	}
+
+	// Names of the KiveData that are in the index after this
+	// reconciliation
+	indexed := map[string]bool{}
+
+File:
+	for _, mapKey := range armedKeys {
+
+		kiveDatas := armed[mapKey]
+
+		filter, err := KiveDataListAccessFilter(kiveDatas)
 		if err != nil {
-			log.Error(err, fmt.Sprintf("Reconcile Error Update map with inode %d for KiveData %s", kiveData.Spec.InodeNo, kiveData.Name))
-			continue Data
+			log.Error(err, fmt.Sprintf("Reconcile Error Get access filter for inode %d", mapKey.Inode))
+			continue File
+		}
+
+		err = tracer.AddInode(mapKey, filter)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("Reconcile Error Update map with inode %d", mapKey.Inode))
+			if ebpf.IsMapFull(err) {
+				for _, kiveData := range kiveDatas {
+					kivePolicyRef, podRef := KiveDataEventReferences(kiveData)
+					r.Recorder.Eventf(kivePolicyRef, corev1.EventTypeWarning, events.InodeMapFullReason,
+						"Inode map full (%d entries), cannot trace %s in container %s of pod %s/%s", ebpf.MapMaxEntries,
+						kiveData.Annotations["path"], kiveData.Annotations["container-name"],
+						kiveData.Annotations["namespace"], kiveData.Annotations["pod-name"])
+					r.Recorder.Eventf(podRef, corev1.EventTypeWarning, events.InodeMapFullReason,
+						"Inode map full (%d entries), cannot trace %s in container %s", ebpf.MapMaxEntries,
+						kiveData.Annotations["path"], kiveData.Annotations["container-name"])
+				}
+			}
+			continue File
+		}
+
+		// A partial allowlist is kept, the trap is still armed
+		allow, err := KiveDataListAllow(kiveDatas)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("Reconcile Error Get allowlist for inode %d", mapKey.Inode))
+		} else if err = tracer.SetAllowlist(mapKey, allow); err != nil {
+			log.Error(err, fmt.Sprintf("Reconcile Error Update allowlist for inode %d", mapKey.Inode))
+		}
+
+		// Without BPF LSM, the accesses are still alerted
+		err = tracer.SetDeny(mapKey, KiveDataListDenyAction(kiveDatas, r.DenyDryRun))
+		if errors.Is(err, ebpf.ErrDenyNotSupported) {
+			for _, kiveData := range kiveDatas {
+				if KiveDataDenyAction(kiveData, r.DenyDryRun) != ebpf.DenyEnforce {
+					continue
+				}
+				kivePolicyRef, podRef := KiveDataEventReferences(kiveData)
+				r.Recorder.Eventf(kivePolicyRef, corev1.EventTypeWarning, events.TrapNotEnforcedReason,
+					"Accesses to %s in container %s of pod %s/%s are alerted but not denied, the %s eBPF backend cannot deny them",
+					kiveData.Annotations["path"], kiveData.Annotations["container-name"],
+					kiveData.Annotations["namespace"], kiveData.Annotations["pod-name"], tracer.Backend())
+				r.Recorder.Eventf(podRef, corev1.EventTypeWarning, events.TrapNotEnforcedReason,
+					"Accesses to %s in container %s are alerted but not denied, the %s eBPF backend cannot deny them",
+					kiveData.Annotations["path"], kiveData.Annotations["container-name"], tracer.Backend())
+			}
+		} else if err != nil {
+			log.Error(err, fmt.Sprintf("Reconcile Error Update deny action for inode %d", mapKey.Inode))
+		}
+
+		for _, kiveData := range kiveDatas {
+			r.index().Set(kiveData)
+			indexed[kiveData.Name] = true
 		}
 	}
 
+	// All the KiveData of this kernel have been visited, forget the
+	// ones that are gone
+	r.index().Retain(indexed)
+
+	tracedInodes, err := tracer.CountInodes()
+	if err != nil {
+		log.Error(err, "Reconcile Error Count traced inodes")
+	} else {
+		metrics.TracedInodes.Set(float64(tracedInodes))
+	}
+
 	return ctrl.Result{}, nil
 }
 
+/*
+ *  Whether another KiveData of kiveDatas, not being deleted, traces
+ *  the same file as kiveData
+ */
+func sharesFile(kiveDatas []kivev2alpha1.KiveData, kiveData kivev2alpha1.KiveData) bool {
+
+	for _, other := range kiveDatas {
+		if other.Name == kiveData.Name || !other.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if ebpf.KiveDataMapKey(other) == ebpf.KiveDataMapKey(kiveData) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *KiveDataReconciler) tracer() ebpf.Tracer {
+
+	if r.Tracer == nil {
+		return ebpf.DefaultTracer
+	}
+
+	return r.Tracer
+}
+
+func (r *KiveDataReconciler) index() *ebpf.InodeIndex {
+
+	if r.Index == nil {
+		return ebpf.DefaultIndex
+	}
+
+	return r.Index
+}
+
 func (r *KiveDataReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 	// Index pod name, namespace and ip so we can query a pod
@@ -223,7 +395,16 @@ func (r *KiveDataReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return fmt.Errorf("SetupWithManager Error Index Pod NodeName: %w", err)
 	}
 
+	// The KiveData of a KivePolicy are deleted when It is deleted or
+	// when Its traps change. All the KiveData are reconciled by any
+	// request, so the KivePolicies share the same one
+	policyRequest := func(ctx context.Context, kivePolicy client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: KernelID}}}
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kivev2alpha1.KiveData{}).
+		Watches(&kivev2alpha1.KivePolicy{}, handler.EnqueueRequestsFromMapFunc(policyRequest),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
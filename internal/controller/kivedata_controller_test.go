@@ -0,0 +1,362 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package controller
+
+import (
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+	ebpf "github.com/San7o/kivebpf/internal/controller/ebpf"
+	ebpftest "github.com/San7o/kivebpf/internal/controller/ebpf/ebpftest"
+)
+
+var _ = Describe("KiveData reconciler", Ordered, func() {
+
+	const (
+		podName     = "data-pod"
+		containerID = "8c2e4a6b0d1f3e5c7a9b2d4f6e8a0c1b3d5f7e9a2c4b6d8f0e1a3c5b7d9f2e4a"
+		ino         = 2001
+		pid         = 4242
+		nsPid       = 7
+	)
+
+	var pod *corev1.Pod
+	kivePolicy := &kivev2alpha1.KivePolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "data-test",
+			Namespace: testNamespaceName,
+		},
+		Spec: kivev2alpha1.KivePolicySpec{
+			AlertVersion: "v1",
+			Sinks: []kivev2alpha1.KiveSink{{
+				Name: "records",
+				Type: "record",
+			}},
+			Traps: []kivev2alpha1.KiveTrap{{
+				Path:   "/etc/shadow",
+				Access: []kivev2alpha1.KiveAccess{kivev2alpha1.KiveAccessRead},
+				Allow: &kivev2alpha1.KiveTrapAllow{
+					Binaries: []string{"passwd"},
+				},
+				Action: kivev2alpha1.KiveTrapActionDeny,
+				Sinks:  []string{"records"},
+				MatchAny: []kivev2alpha1.KiveTrapMatch{{
+					PodName:   podName,
+					Namespace: testNamespaceName,
+				}},
+			}},
+		},
+	}
+
+	BeforeAll(func() {
+		pod = createTestPod(podName, NodeName, containerID, ino)
+		Expect(k8sClient.Create(ctx, kivePolicy)).To(Succeed())
+	})
+
+	AfterAll(func() {
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, kivePolicy))).To(Succeed())
+		deleteTestPod(pod)
+	})
+
+	It("Should arm the trap in the tracer", func() {
+
+		Eventually(func(g Gomega) {
+			filter, ok := fakeTracer.Filter(fakeMapKey(ino))
+			g.Expect(ok).To(BeTrue())
+			g.Expect(filter).To(Equal(ebpf.AccessRead))
+			g.Expect(fakeTracer.Allowlist(fakeMapKey(ino))).NotTo(BeNil())
+			g.Expect(fakeTracer.DenyAction(fakeMapKey(ino))).To(Equal(ebpf.DenyEnforce))
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("Should add the finalizer to the KiveData", func() {
+
+		Eventually(func(g Gomega) {
+			kiveDatas := podKiveData(g, podName)
+			g.Expect(kiveDatas).To(HaveLen(1))
+			g.Expect(kiveDatas[0].Finalizers).To(ContainElement(KiveDataFinalizerName))
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("Should deliver the accesses to the sinks of the trap", func() {
+
+		comm := [16]int8{}
+		for i, char := range "cat" {
+			comm[i] = int8(char)
+		}
+		Expect(fakeTracer.Emit(ebpf.BpfLogData{
+			Pid:     pid,
+			Tgid:    pid,
+			NsPid:   nsPid,
+			NsTgid:  nsPid,
+			Dev:     1,
+			Ino:     ino,
+			Mask:    4,
+			Comm:    comm,
+			Blocked: uint32(ebpf.DenyEnforce),
+		})).To(BeTrue())
+
+		Eventually(func(g Gomega) {
+			recordList := &kivev2alpha1.KiveAlertRecordList{}
+			g.Expect(k8sClient.List(ctx, recordList, client.InNamespace(testNamespaceName))).To(Succeed())
+			g.Expect(recordList.Items).To(ContainElement(HaveField("Alert", And(
+				HaveField("PolicyName", kivePolicy.Name),
+				HaveField("Metadata.Path", "/etc/shadow"),
+				HaveField("Metadata.Inode", BeEquivalentTo(ino)),
+				HaveField("Process.Pid", BeEquivalentTo(pid)),
+				HaveField("Process.NsPid", BeEquivalentTo(nsPid)),
+				HaveField("Process.Binary", "cat"),
+				HaveField("Pod.Name", podName),
+				HaveField("Blocked", BeTrue()),
+			))))
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("Should read the executable, the cwd and the arguments of the process", func() {
+
+		// The process of the access is the test itself
+		hostPid := os.Getpid()
+		executable, err := os.Executable()
+		Expect(err).NotTo(HaveOccurred())
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeTracer.Emit(ebpf.BpfLogData{
+			Pid:    int32(hostPid),
+			Tgid:   uint32(hostPid),
+			NsPid:  1,
+			NsTgid: 1,
+			Dev:    1,
+			Ino:    ino,
+			Mask:   4,
+		})).To(BeTrue())
+
+		Eventually(func(g Gomega) {
+			recordList := &kivev2alpha1.KiveAlertRecordList{}
+			g.Expect(k8sClient.List(ctx, recordList, client.InNamespace(testNamespaceName))).To(Succeed())
+			g.Expect(recordList.Items).To(ContainElement(HaveField("Alert", And(
+				HaveField("Process.Pid", BeEquivalentTo(hostPid)),
+				HaveField("Process.NsPid", BeEquivalentTo(1)),
+				HaveField("Process.Executable", executable),
+				HaveField("Process.Cwd", cwd),
+				HaveField("Process.Arguments", strings.Join(os.Args[1:], " ")),
+			))))
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("Should prefer the paths read by the eBPF program to the procfs", func() {
+
+		hostPid := os.Getpid()
+
+		Expect(fakeTracer.Emit(ebpf.BpfLogData{
+			Pid:    int32(hostPid),
+			Tgid:   uint32(hostPid),
+			NsPid:  2,
+			NsTgid: 2,
+			Dev:    1,
+			Ino:    ino,
+			Mask:   4,
+			Exe:    ebpftest.Path("/usr/bin/cat"),
+			Cwd:    ebpftest.Path("/"),
+		})).To(BeTrue())
+
+		Eventually(func(g Gomega) {
+			recordList := &kivev2alpha1.KiveAlertRecordList{}
+			g.Expect(k8sClient.List(ctx, recordList, client.InNamespace(testNamespaceName))).To(Succeed())
+			g.Expect(recordList.Items).To(ContainElement(HaveField("Alert", And(
+				HaveField("Process.NsPid", BeEquivalentTo(2)),
+				HaveField("Process.Executable", "/usr/bin/cat"),
+				HaveField("Process.Cwd", "/"),
+				// Only read from procfs
+				HaveField("Process.Arguments", strings.Join(os.Args[1:], " ")),
+			))))
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("Should not send the accesses to the untraced inodes", func() {
+		Expect(fakeTracer.Emit(ebpf.BpfLogData{Pid: pid, Dev: 1, Ino: ino + 1})).To(BeFalse())
+	})
+
+	It("Should report the lost events to the sinks of the KivePolicy", func() {
+
+		fakeTracer.AddLost(3)
+
+		Eventually(func(g Gomega) {
+			recordList := &kivev2alpha1.KiveAlertRecordList{}
+			g.Expect(k8sClient.List(ctx, recordList, client.InNamespace(testNamespaceName))).To(Succeed())
+			g.Expect(recordList.Items).To(ContainElement(HaveField("Alert", And(
+				HaveField("PolicyName", kivePolicy.Name),
+				HaveField("Lost", BeEquivalentTo(3)),
+			))))
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("Should disarm the trap when the KiveData is deleted", func() {
+
+		Expect(k8sClient.Delete(ctx, kivePolicy)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			_, ok := fakeTracer.Filter(fakeMapKey(ino))
+			g.Expect(ok).To(BeFalse())
+			g.Expect(fakeTracer.Allowlist(fakeMapKey(ino))).To(BeNil())
+			g.Expect(fakeTracer.DenyAction(fakeMapKey(ino))).To(BeZero())
+			g.Expect(podKiveData(g, podName)).To(BeEmpty())
+		}, timeout, interval).Should(Succeed())
+	})
+})
+
+var _ = Describe("KiveData reconciler with overlapping traps", Ordered, func() {
+
+	const (
+		podName     = "overlap-pod"
+		containerID = "6d8f0a2c4e1b3d5f7a9c0e2b4d6f8a1c3e5b7d9f0a2c4e6b8d1f3a5c7e9b0d2f"
+		ino         = 2101
+		readPid     = 5151
+		writePid    = 5252
+	)
+
+	var pod *corev1.Pod
+	readTrap := kivev2alpha1.KiveTrap{
+		Path:     "/etc/shadow",
+		Access:   []kivev2alpha1.KiveAccess{kivev2alpha1.KiveAccessRead},
+		Allow:    &kivev2alpha1.KiveTrapAllow{Binaries: []string{"passwd"}},
+		Sinks:    []string{"records"},
+		Metadata: map[string]string{"trap": "read"},
+		MatchAny: []kivev2alpha1.KiveTrapMatch{{
+			PodName:   podName,
+			Namespace: testNamespaceName,
+		}},
+	}
+	writeTrap := kivev2alpha1.KiveTrap{
+		Path:     "/etc/shadow",
+		Access:   []kivev2alpha1.KiveAccess{kivev2alpha1.KiveAccessWrite},
+		Action:   kivev2alpha1.KiveTrapActionDeny,
+		Sinks:    []string{"records"},
+		Metadata: map[string]string{"trap": "write"},
+		MatchAny: []kivev2alpha1.KiveTrapMatch{{
+			PodName:   podName,
+			Namespace: testNamespaceName,
+		}},
+	}
+	kivePolicy := &kivev2alpha1.KivePolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "overlap-test",
+			Namespace: testNamespaceName,
+		},
+		Spec: kivev2alpha1.KivePolicySpec{
+			AlertVersion: "v1",
+			Sinks: []kivev2alpha1.KiveSink{{
+				Name: "records",
+				Type: "record",
+			}},
+			Traps: []kivev2alpha1.KiveTrap{readTrap, writeTrap},
+		},
+	}
+
+	BeforeAll(func() {
+		pod = createTestPod(podName, NodeName, containerID, ino)
+		Expect(k8sClient.Create(ctx, kivePolicy)).To(Succeed())
+	})
+
+	AfterAll(func() {
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, kivePolicy))).To(Succeed())
+		deleteTestPod(pod)
+	})
+
+	It("Should create one KiveData for each trap of the file", func() {
+
+		readTrapID, err := KiveTrapHashID(readTrap, "v1")
+		Expect(err).NotTo(HaveOccurred())
+		writeTrapID, err := KiveTrapHashID(writeTrap, "v1")
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			g.Expect(podKiveData(g, podName)).To(ConsistOf(
+				And(HaveField("ObjectMeta.Labels", HaveKeyWithValue(TrapIDLabel, readTrapID)),
+					HaveField("ObjectMeta.Annotations", HaveKeyWithValue("access", "read"))),
+				And(HaveField("ObjectMeta.Labels", HaveKeyWithValue(TrapIDLabel, writeTrapID)),
+					HaveField("ObjectMeta.Annotations", HaveKeyWithValue("access", "write"))),
+			))
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("Should arm the file with the traps merged", func() {
+
+		Eventually(func(g Gomega) {
+			filter, ok := fakeTracer.Filter(fakeMapKey(ino))
+			g.Expect(ok).To(BeTrue())
+			g.Expect(filter).To(Equal(ebpf.AccessRead | ebpf.AccessWrite))
+			// The write trap allows nobody
+			g.Expect(fakeTracer.Allowlist(fakeMapKey(ino))).To(BeNil())
+			g.Expect(fakeTracer.DenyAction(fakeMapKey(ino))).To(Equal(ebpf.DenyEnforce))
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("Should alert only the traps matched by the access", func() {
+
+		passwd := [16]int8{}
+		for i, char := range "passwd" {
+			passwd[i] = int8(char)
+		}
+		Expect(fakeTracer.Emit(ebpf.BpfLogData{Pid: readPid, Tgid: readPid, Dev: 1, Ino: ino, Mask: 4})).To(BeTrue())
+		Expect(fakeTracer.Emit(ebpf.BpfLogData{Pid: writePid, Tgid: writePid, Dev: 1, Ino: ino, Mask: 2, Comm: passwd})).To(BeTrue())
+
+		alertsOf := func(g Gomega, pid int) []kivev2alpha1.KiveAlert {
+			recordList := &kivev2alpha1.KiveAlertRecordList{}
+			g.Expect(k8sClient.List(ctx, recordList, client.InNamespace(testNamespaceName))).To(Succeed())
+			alerts := []kivev2alpha1.KiveAlert{}
+			for _, record := range recordList.Items {
+				if record.Alert.Process.Pid == int32(pid) {
+					alerts = append(alerts, record.Alert)
+				}
+			}
+			return alerts
+		}
+
+		Eventually(func(g Gomega) {
+			g.Expect(alertsOf(g, readPid)).To(ConsistOf(HaveField("CustomMetadata", HaveKeyWithValue("trap", "read"))))
+			// passwd is allowed by the read trap only
+			g.Expect(alertsOf(g, writePid)).To(ConsistOf(HaveField("CustomMetadata", HaveKeyWithValue("trap", "write"))))
+		}, timeout, interval).Should(Succeed())
+		Consistently(func(g Gomega) {
+			g.Expect(alertsOf(g, readPid)).To(HaveLen(1))
+			g.Expect(alertsOf(g, writePid)).To(HaveLen(1))
+		}, "1s", interval).Should(Succeed())
+	})
+
+	It("Should keep the file armed for the remaining trap", func() {
+
+		Eventually(func(g Gomega) {
+			current := &kivev2alpha1.KivePolicy{}
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(kivePolicy), current)).To(Succeed())
+			current.Spec.Traps = []kivev2alpha1.KiveTrap{readTrap}
+			g.Expect(k8sClient.Update(ctx, current)).To(Succeed())
+		}, timeout, interval).Should(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(podKiveData(g, podName)).To(HaveLen(1))
+			filter, ok := fakeTracer.Filter(fakeMapKey(ino))
+			g.Expect(ok).To(BeTrue())
+			g.Expect(filter).To(Equal(ebpf.AccessRead))
+			g.Expect(fakeTracer.Allowlist(fakeMapKey(ino))).To(Equal(readTrap.Allow))
+			g.Expect(fakeTracer.DenyAction(fakeMapKey(ino))).To(BeZero())
+		}, timeout, interval).Should(Succeed())
+	})
+})
@@ -0,0 +1,82 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+var _ = Describe("KivePod reconciler", Ordered, func() {
+
+	const (
+		podName     = "pod-pod"
+		containerID = "3b5d7f9a1c2e4f6a8b0d1e3f5a7c9b2d4e6f8a0c1b3d5e7f9a2c4e6b8d0f1a3c"
+		ino         = 3001
+	)
+
+	var pod *corev1.Pod
+	kivePolicy := &kivev2alpha1.KivePolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-test",
+			Namespace: testNamespaceName,
+		},
+		Spec: kivev2alpha1.KivePolicySpec{
+			AlertVersion: "v1",
+			Traps: []kivev2alpha1.KiveTrap{{
+				Path: "/etc/hosts",
+				MatchAny: []kivev2alpha1.KiveTrapMatch{{
+					PodName:   podName,
+					Namespace: testNamespaceName,
+				}},
+			}},
+		},
+	}
+
+	BeforeAll(func() {
+		Expect(k8sClient.Create(ctx, kivePolicy)).To(Succeed())
+	})
+
+	AfterAll(func() {
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, kivePolicy))).To(Succeed())
+		deleteTestPod(pod)
+	})
+
+	It("Should trap a pod created after the KivePolicy", func() {
+
+		pod = createTestPod(podName, NodeName, containerID, ino)
+
+		Eventually(func(g Gomega) {
+			g.Expect(podKiveData(g, podName)).To(HaveLen(1))
+			_, ok := fakeTracer.Filter(fakeMapKey(ino))
+			g.Expect(ok).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("Should delete the KiveData of a deleted pod", func() {
+
+		deleteTestPod(pod)
+		fakeRuntime.RemoveContainer(containerID)
+
+		Eventually(func(g Gomega) {
+			g.Expect(podKiveData(g, podName)).To(BeEmpty())
+			_, ok := fakeTracer.Filter(fakeMapKey(ino))
+			g.Expect(ok).To(BeFalse())
+		}, timeout, interval).Should(Succeed())
+	})
+})
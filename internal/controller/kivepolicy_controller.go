@@ -14,34 +14,61 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
 	comm "github.com/San7o/kivebpf/internal/controller/comm"
 	container "github.com/San7o/kivebpf/internal/controller/container"
+	events "github.com/San7o/kivebpf/internal/controller/events"
+	sink "github.com/San7o/kivebpf/internal/controller/sink"
 )
 
 const (
 	KivePolicyFinalizerName = "kivepolicy.kivebpf.san7o.github.io/finalizer"
+	// Default of the --rescan-interval flag
+	DefaultRescanInterval = 10 * time.Minute
 )
 
 var (
 	KernelID string = ""
+	// Name of the node where the operator is running
+	NodeName string = ""
 )
 
 type KivePolicyReconciler struct {
 	client.Client
 	UncachedClient client.Reader
 	Scheme         *runtime.Scheme
+	// (optional) Records Events when a trap cannot be armed
+	Recorder *events.Recorder
+	// How often the paths of the traps are resolved again, to find
+	// the new files and the replaced ones. If 0, they are resolved
+	// again only when the KivePolicies or the pods change
+	RescanInterval time.Duration
+	// Runtimes of the containers by the prefix of their ids. If nil,
+	// container.ContainerRuntimes is used
+	Runtimes map[string]container.Runtime
 }
 
 // +kubebuilder:rbac:groups=kivebpf.san7o.github.io,resources=kivepolicies,verbs=get;list;watch;create;update;patch;delete
@@ -53,9 +80,13 @@ type KivePolicyReconciler struct {
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=deployments/status,verbs=get
 
+// Events recorded on the KivePolicies and the Pods
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
 // The KivePolicy reconciliation is responsible for the following:
 //   - For each KivePolicy, fetch files' information such as the inode
-//     number from the matched container.
+//     number from the matched containers of the pods scheduled on
+//     this node.
 //   - create KiveData resources with the previously fetched information
 //     if not already present.
 func (r *KivePolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -70,6 +101,9 @@ func (r *KivePolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, fmt.Errorf("Reconcile Error Failed to get KivePolicy resource: %w", err)
 	}
 
+	// Requeued if the paths of the traps should be resolved again
+	result := ctrl.Result{}
+
 	// Loop over the KivePolicies and check if all the corresponsing
 	// KiveData exist. In case they does not, a new KiveData is created.
 Policy:
@@ -116,6 +150,9 @@ Policy:
 			}
 		}
 
+		// Status of the traps in the containers of this node
+		trapStatuses := []kivev2alpha1.KiveTrapStatus{}
+
 	Trap:
 		for _, kiveTrap := range kivePolicy.Spec.Traps {
 
@@ -129,6 +166,45 @@ Policy:
 				log.Error(err, fmt.Sprintf("Reconcile Error Generate TrapID for Trap at path %s in KivePolicy %s", kiveTrap.Path, kivePolicy.Name))
 				continue Trap
 			}
+			if slices.ContainsFunc(trapStatuses, func(trapStatus kivev2alpha1.KiveTrapStatus) bool {
+				return trapStatus.ID == trapID
+			}) {
+				// The same trap is already declared in this policy
+				continue Trap
+			}
+			trapStatuses = append(trapStatuses, kivev2alpha1.KiveTrapStatus{
+				ID:   trapID,
+				Path: kiveTrap.Path,
+			})
+			trapStatus := &trapStatuses[len(trapStatuses)-1]
+
+			// The paths are resolved again periodically to find the new
+			// files and the replaced ones
+			if r.RescanInterval > 0 {
+				result.RequeueAfter = r.RescanInterval
+			}
+
+			// An unknown sink does not disarm the trap, the alerts are
+			// still sent to the sinks that could be resolved
+			kiveSinks, err := sink.ResolveTrapSinks(kiveTrap, kivePolicy)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("Reconcile Error Resolve sinks for Trap at path %s in KivePolicy %s", kiveTrap.Path, kivePolicy.Name))
+			}
+			jsonSinks, err := json.Marshal(kiveSinks)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("Reconcile Error Json Marshal sinks for Trap at path %s in KivePolicy %s", kiveTrap.Path, kivePolicy.Name))
+				continue Trap
+			}
+			jsonRateLimit, err := json.Marshal(kiveTrap.RateLimit)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("Reconcile Error Json Marshal rate limit for Trap at path %s in KivePolicy %s", kiveTrap.Path, kivePolicy.Name))
+				continue Trap
+			}
+			jsonAllow, err := json.Marshal(kiveTrap.Allow)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("Reconcile Error Json Marshal allowlist for Trap at path %s in KivePolicy %s", kiveTrap.Path, kivePolicy.Name))
+				continue Trap
+			}
 
 		Match:
 			for _, kiveTrapMatch := range kiveTrap.MatchAny {
@@ -147,15 +223,24 @@ Policy:
 				if kiveTrapMatch.IP != "" {
 					matchingFields["metadata.podIP"] = kiveTrapMatch.IP
 				}
+				// The cache only holds the pods of this node, see
+				// NodeCacheOptions
 				podList := &corev1.PodList{}
-				err = r.UncachedClient.List(ctx, podList, labelMap, matchingFields)
+				err = r.Client.List(ctx, podList, labelMap, matchingFields)
 				if err != nil {
 					log.Error(err, "Reconcile Error Failed to list pods")
 					continue Match
 				}
 
+			Pod:
 				for _, pod := range podList.Items {
 
+					// The containers of the other nodes are not visible
+					// from the runtime and the procfs of this node
+					if pod.Spec.NodeName != NodeName {
+						continue Pod
+					}
+
 				Container:
 					for _, containerStatus := range pod.Status.ContainerStatuses {
 
@@ -179,98 +264,306 @@ Policy:
 						}
 						matchedContainers[matchID] = true
 
-						containerData, err := container.GetContainerData(ctx, containerStatus, kiveTrap)
+						// Each node reports the status of Its pods
+						trapContainerStatus := kivev2alpha1.KiveTrapContainerStatus{
+							Namespace: pod.Namespace,
+							Pod:       pod.Name,
+							Container: containerStatus.Name,
+							Node:      pod.Spec.NodeName,
+						}
+
+						containerData, err := r.getContainerData(ctx, containerStatus, kiveTrap)
+						if errors.Is(err, container.ErrContainerNotFound) {
+							// The container is gone or not started yet, the
+							// next update of the pod reconciles It again
+							continue Container
+						}
 						if err != nil {
 							log.Error(err, fmt.Sprintf("Reconcile Error Get contianer data for container %s", containerStatus.Name))
+							trapContainerStatus.State = kivev2alpha1.KiveTrapMissing
+							trapContainerStatus.LastError = err.Error()
+							trapStatus.Containers = append(trapStatus.Containers, trapContainerStatus)
+							r.recordTrapNotArmed(kivePolicy, pod, kiveTrap, containerStatus.Name, err)
 							continue Container
 						}
 						if containerData.ShouldRequeue {
 							return ctrl.Result{Requeue: true}, nil
 						}
-						if !containerData.IsFound { // Inode was not found
+						if !containerData.IsFound {
+							// The file does not exist and the trap does not
+							// create It
+							trapContainerStatus.State = kivev2alpha1.KiveTrapMissing
+							trapContainerStatus.LastError = fmt.Sprintf("%s not found in the container", kiveTrap.Path)
+							trapStatus.Containers = append(trapStatus.Containers, trapContainerStatus)
 							continue Container
 						}
 						inode := containerData.Ino
 						dev := containerData.DevID
 
-						// Here we are crating a new KiveData since an already existing
-						// one for this Pod and this KivePolicy has not been found
-						kiveData := &kivev2alpha1.KiveData{
-							TypeMeta: metav1.TypeMeta{
-								Kind:       "KiveData",
-								APIVersion: "kivebpf.san7o.github.io/v2alpha1",
-							},
-							ObjectMeta: metav1.ObjectMeta{
-								// Give it an unique name
-								Name:      NewKiveDataName(inode, dev, pod, containerStatus),
-								Namespace: kivev2alpha1.Namespace,
-								// Annotations are used as information for the KiveAlert
-								Annotations: map[string]string{
-									"kive-alert-version": kivePolicy.Spec.AlertVersion,
-									"kive-policy-name":   kivePolicy.Name,
-									"callback":           kiveTrap.Callback,
-									"pod-name":           pod.Name,
-									"namespace":          pod.Namespace,
-									"pod-ip":             pod.Status.PodIP,
-									"path":               kiveTrap.Path,
-									"container-id":       containerData.ID,
-									"container-name":     containerData.Name,
-									"node-name":          pod.Spec.NodeName,
+						trapContainerStatus.State = kivev2alpha1.KiveTrapFound
+						if containerData.Created {
+							trapContainerStatus.State = kivev2alpha1.KiveTrapCreated
+						}
+						trapContainerStatus.Inode = inode
+						trapContainerStatus.Dev = dev
+
+						// The KiveData created for this container by the previous
+						// reconciliations, used to find the replaced files
+						previousKiveData, listErr := r.listTrapKiveData(ctx, trapID, containerData.ID)
+						if listErr != nil {
+							log.Error(listErr, fmt.Sprintf("Reconcile Error List KiveData of Trap at path %s", kiveTrap.Path))
+						}
+						previousByPath := map[string]kivev2alpha1.KiveData{}
+						for _, kiveData := range previousKiveData {
+							previousByPath[kiveData.Annotations["path"]] = kiveData
+						}
+						trapContainerStatus.LastReplaced = lastReplaced(kivePolicy, trapID, trapContainerStatus)
+
+						// Here we are crating a new KiveData for each trapped file
+						// since an already existing one for this Pod and this
+						// KivePolicy has not been found
+						kiveDataNames := map[string]bool{}
+						for _, file := range containerData.Files {
+							kiveData := &kivev2alpha1.KiveData{
+								TypeMeta: metav1.TypeMeta{
+									Kind:       "KiveData",
+									APIVersion: "kivebpf.san7o.github.io/v2alpha1",
 								},
-								Labels: map[string]string{
-									// The trap-id is used to link this KiveData to this trap
-									TrapIDLabel:        trapID,
-									comm.KernelIDLabel: KernelID,
+								ObjectMeta: metav1.ObjectMeta{
+									// Give it an unique name
+									Name:      NewKiveDataName(file.Ino, file.DevID, pod, containerStatus, trapID),
+									Namespace: kivev2alpha1.Namespace,
+									// Annotations are used as information for the KiveAlert
+									Annotations: map[string]string{
+										"kive-alert-version":    kivePolicy.Spec.AlertVersion,
+										"kive-policy-name":      kivePolicy.Name,
+										"kive-policy-namespace": kivePolicy.Namespace,
+										"kive-policy-uid":       string(kivePolicy.UID),
+										"callback":              kiveTrap.Callback,
+										"sinks":                 string(jsonSinks),
+										"pod-name":              pod.Name,
+										"pod-uid":               string(pod.UID),
+										"namespace":             pod.Namespace,
+										"pod-ip":                pod.Status.PodIP,
+										"path":                  file.Path,
+										"container-id":          containerData.ID,
+										"container-name":        containerData.Name,
+										"node-name":             pod.Spec.NodeName,
+									},
+									Labels: map[string]string{
+										// The trap-id is used to link this KiveData to this trap
+										TrapIDLabel:        trapID,
+										comm.KernelIDLabel: KernelID,
+									},
+									Finalizers: []string{KiveDataFinalizerName},
 								},
-								Finalizers: []string{KiveDataFinalizerName},
-							},
-							Spec: kivev2alpha1.KiveDataSpec{
-								InodeNo:  inode,
-								DevID:    dev,
-								Metadata: map[string]string{},
-							},
+								Spec: kivev2alpha1.KiveDataSpec{
+									InodeNo:  file.Ino,
+									DevID:    file.DevID,
+									Metadata: map[string]string{},
+								},
+							}
+
+							for key, val := range kiveTrap.Metadata {
+								kiveData.Spec.Metadata[key] = val
+							}
+							if kiveTrap.DedupWindow != nil {
+								kiveData.Annotations["dedup-window"] = kiveTrap.DedupWindow.Duration.String()
+							}
+							if kiveTrap.RateLimit != nil {
+								kiveData.Annotations["rate-limit"] = string(jsonRateLimit)
+							}
+							if len(kiveTrap.Access) > 0 {
+								access := []string{}
+								for _, kind := range kiveTrap.Access {
+									access = append(access, string(kind))
+								}
+								kiveData.Annotations["access"] = strings.Join(access, ",")
+							}
+							if kiveTrap.Allow != nil {
+								kiveData.Annotations["allow"] = string(jsonAllow)
+							}
+							if kiveTrap.Action == kivev2alpha1.KiveTrapActionDeny {
+								kiveData.Annotations["action"] = string(kiveTrap.Action)
+								if kiveTrap.DryRun {
+									kiveData.Annotations["dry-run"] = "true"
+								}
+							}
+							if expandsPath(kiveTrap) {
+								kiveData.Annotations["trap-path"] = kiveTrap.Path
+							}
+
+							// The KiveData is written only if It changed since the
+							// last reconciliation
+							previous, ok := previousByPath[file.Path]
+							if !ok || !kiveDataUpToDate(previous, kiveData) {
+								err = r.Client.Patch(ctx, kiveData, client.Apply, client.ForceOwnership, client.FieldOwner(FieldOwnerKiveController))
+								if err != nil {
+									log.Error(err, fmt.Sprintf("Reconcile Error patch KiveData resource %s", kiveData.Name))
+									trapContainerStatus.LastError = err.Error()
+									continue
+								}
+								log.Info("Created / Updated KiveData resource.")
+							}
+							kiveDataNames[kiveData.Name] = true
+
+							if ok && (previous.Spec.InodeNo != file.Ino || previous.Spec.DevID != file.DevID) {
+								log.Info("Trapped file replaced, the trap has been armed on the new file", "path", file.Path,
+									"container", containerStatus.Name, "pod", pod.Name, "namespace", pod.Namespace,
+									"old-inode", previous.Spec.InodeNo, "inode", file.Ino)
+								now := metav1.Now()
+								trapContainerStatus.State = kivev2alpha1.KiveTrapReplaced
+								trapContainerStatus.LastReplaced = &now
+								r.recordTrapFileReplaced(kivePolicy, pod, containerStatus.Name, file.Path, previous.Spec.InodeNo, file.Ino)
+							}
 						}
-
-						for key, val := range kiveTrap.Metadata {
-							kiveData.Spec.Metadata[key] = val
+						if listErr == nil && trapContainerStatus.LastError == "" {
+							// The files that were replaced or are not matched
+							// anymore are not trapped anymore
+							err = r.deleteStaleKiveData(ctx, previousKiveData, kiveDataNames)
+							if err != nil {
+								log.Error(err, fmt.Sprintf("Reconcile Error Delete stale KiveData of Trap at path %s", kiveTrap.Path))
+							}
 						}
-
-						err = r.Client.Patch(ctx, kiveData, client.Apply, client.ForceOwnership, client.FieldOwner(FieldOwnerKiveController))
-						if err != nil {
-							log.Error(err, fmt.Sprintf("Reconcile Error patch KiveData resource %s", kiveData.Name))
-							continue Container
-						}
-						log.Info("Created / Updated KiveData resource.")
+						trapStatus.Containers = append(trapStatus.Containers, trapContainerStatus)
 					}
 				}
 			}
 		}
+
+		err = r.updateStatus(ctx, kivePolicy, trapStatuses)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("Reconcile Error Update status of KivePolicy %s", kivePolicy.Name))
+		}
+	}
+
+	// The KiveData of the deleted KivePolicies are deleted by the
+	// KiveData reconciliation, which watches the KivePolicies
+	return result, nil
+}
+
+/*
+ *  Whether the files of a trap can change without the KivePolicy
+ *  changing, because It is recursive or Its path is a pattern
+ */
+func expandsPath(kiveTrap kivev2alpha1.KiveTrap) bool {
+	return kiveTrap.Recursive || container.IsPathPattern(kiveTrap.Path)
+}
+
+/*
+ *  Whether previous, created by a previous reconciliation, already
+ *  has the name, the spec, the annotations, the labels and the
+ *  finalizers of desired. The annotations that depend on the trap
+ *  cannot be removed without changing the trap id, so previous may
+ *  have more annotations than desired.
+ */
+func kiveDataUpToDate(previous kivev2alpha1.KiveData, desired *kivev2alpha1.KiveData) bool {
+
+	if previous.Name != desired.Name || !previous.DeletionTimestamp.IsZero() {
+		return false
+	}
+	if !equality.Semantic.DeepEqual(previous.Spec, desired.Spec) {
+		return false
+	}
+	for key, value := range desired.Annotations {
+		if annotation, ok := previous.Annotations[key]; !ok || annotation != value {
+			return false
+		}
+	}
+	for key, value := range desired.Labels {
+		if label, ok := previous.Labels[key]; !ok || label != value {
+			return false
+		}
+	}
+	for _, finalizer := range desired.Finalizers {
+		if !slices.Contains(previous.Finalizers, finalizer) {
+			return false
+		}
 	}
 
-	// Force a reconciliation for KiveData, which will delete any
-	// KiveData that does not belong anymore to a KivePolicy.
-	// We trigger a reconciliation by updating an annotation with the
-	// current time.
+	return true
+}
+
+/*
+ *  List the KiveData of a trap in a container
+ */
+func (r *KivePolicyReconciler) listTrapKiveData(ctx context.Context, trapID string, containerID string) ([]kivev2alpha1.KiveData, error) {
+
 	kiveDataList := &kivev2alpha1.KiveDataList{}
-	err = r.UncachedClient.List(ctx, kiveDataList)
-	if err != nil { // Fatal
-		return ctrl.Result{}, fmt.Errorf("Reconcile Error Failed to get KiveData resource: %w", err)
+	err := r.UncachedClient.List(ctx, kiveDataList, client.InNamespace(kivev2alpha1.Namespace),
+		client.MatchingLabels{TrapIDLabel: trapID})
+	if err != nil {
+		return nil, fmt.Errorf("listTrapKiveData Error List KiveData: %w", err)
+	}
+
+	kiveDatas := []kivev2alpha1.KiveData{}
+	for _, kiveData := range kiveDataList.Items {
+		if kiveData.Annotations["container-id"] == containerID {
+			kiveDatas = append(kiveDatas, kiveData)
+		}
 	}
 
-	if len(kiveDataList.Items) == 0 {
-		return ctrl.Result{}, nil
+	return kiveDatas, nil
+}
+
+/*
+ *  Delete the KiveData that are not in keep, used to stop tracing
+ *  the files that were replaced or that are not matched anymore by
+ *  a recursive trap or a pattern.
+ */
+func (r *KivePolicyReconciler) deleteStaleKiveData(ctx context.Context, kiveDatas []kivev2alpha1.KiveData, keep map[string]bool) error {
+
+	var errs []error
+	for _, kiveData := range kiveDatas {
+		if keep[kiveData.Name] {
+			continue
+		}
+		if err := r.Client.Delete(ctx, &kiveData); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("deleteStaleKiveData Error Delete KiveData %s: %w", kiveData.Name, err))
+		}
 	}
-	kiveData := kiveDataList.Items[0]
-	orig := kiveData.DeepCopy()
-	if kiveData.Annotations == nil {
-		kiveData.Annotations = map[string]string{}
+
+	return errors.Join(errs...)
+}
+
+/*
+ *  Get the data of a container from Its Runtime among r.Runtimes
+ */
+func (r *KivePolicyReconciler) getContainerData(ctx context.Context, containerStatus corev1.ContainerStatus, kiveTrap kivev2alpha1.KiveTrap) (container.ContainerData, error) {
+
+	runtimes := r.Runtimes
+	if runtimes == nil {
+		runtimes = container.ContainerRuntimes
 	}
-	kiveData.Annotations["force-reconcile"] = time.Now().Format(time.RFC3339)
-	err = r.Patch(ctx, &kiveData, client.MergeFrom(orig))
-	if err != nil {
-		log.Error(err, fmt.Sprintf("Reconcile Error Patch KiveData %s", kiveData.Name))
+
+	return container.GetRuntimeContainerData(ctx, runtimes, containerStatus, kiveTrap)
+}
+
+func (r *KivePolicyReconciler) recordTrapNotArmed(kivePolicy kivev2alpha1.KivePolicy, pod corev1.Pod, kiveTrap kivev2alpha1.KiveTrap, containerName string, err error) {
+
+	reason := events.TrapNotArmedReason
+	message := "cannot be armed"
+	if errors.Is(err, fs.ErrNotExist) {
+		reason = events.TrapPathNotFoundReason
+		message = "not found"
 	}
-	return ctrl.Result{}, nil
+
+	r.Recorder.Eventf(&kivePolicy, corev1.EventTypeWarning, reason,
+		"Trap path %s %s in container %s of pod %s/%s: %v", kiveTrap.Path, message,
+		containerName, pod.Namespace, pod.Name, err)
+	r.Recorder.Eventf(&pod, corev1.EventTypeWarning, reason,
+		"Trap path %s of KivePolicy %s/%s %s in container %s: %v", kiveTrap.Path,
+		kivePolicy.Namespace, kivePolicy.Name, message, containerName, err)
+}
+
+func (r *KivePolicyReconciler) recordTrapFileReplaced(kivePolicy kivev2alpha1.KivePolicy, pod corev1.Pod, containerName string, path string, oldInode uint64, inode uint64) {
+
+	r.Recorder.Eventf(&kivePolicy, corev1.EventTypeWarning, events.TrapFileReplacedReason,
+		"Trapped file %s replaced in container %s of pod %s/%s (inode %d, was %d), the trap has been re-armed",
+		path, containerName, pod.Namespace, pod.Name, inode, oldInode)
+	r.Recorder.Eventf(&pod, corev1.EventTypeWarning, events.TrapFileReplacedReason,
+		"Trapped file %s of KivePolicy %s/%s replaced in container %s (inode %d, was %d), the trap has been re-armed",
+		path, kivePolicy.Namespace, kivePolicy.Name, containerName, inode, oldInode)
 }
 
 func (r *KivePolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -302,7 +595,32 @@ func (r *KivePolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return fmt.Errorf("SetupWithManager Error Index Pod Ip: %w", err)
 	}
 
+	// A pod of this node is matched again when It changes. All the
+	// KivePolicies are reconciled by any request, so the pods share
+	// the same one
+	podRequest := func(ctx context.Context, pod client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: NodeName}}}
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kivev2alpha1.KivePolicy{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(podRequest)).
 		Complete(r)
 }
+
+/*
+ *  Options of the cache of a manager that only holds the pods
+ *  scheduled on nodeName, so that each instance of the operator
+ *  watches the pods of Its node instead of all the pods of the
+ *  cluster.
+ */
+func NodeCacheOptions(nodeName string) cache.Options {
+
+	return cache.Options{
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.Pod{}: {
+				Field: fields.OneTermEqualSelector("spec.nodeName", nodeName),
+			},
+		},
+	}
+}
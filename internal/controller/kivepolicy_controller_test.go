@@ -0,0 +1,214 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+	container "github.com/San7o/kivebpf/internal/controller/container"
+)
+
+var _ = Describe("KivePolicy reconciler", Ordered, func() {
+
+	const (
+		podName     = "policy-pod"
+		containerID = "5f1a0c3e2b7d4a6f8e9c0b1d2a3f4e5d6c7b8a9f0e1d2c3b4a5f6e7d8c9b0a1f"
+		ino         = 1001
+	)
+
+	var pod *corev1.Pod
+	kiveTrap := kivev2alpha1.KiveTrap{
+		Path:     "/etc/passwd",
+		Access:   []kivev2alpha1.KiveAccess{kivev2alpha1.KiveAccessWrite},
+		Metadata: map[string]string{"team": "kive"},
+		MatchAny: []kivev2alpha1.KiveTrapMatch{{
+			PodName:   podName,
+			Namespace: testNamespaceName,
+		}},
+	}
+	kivePolicy := &kivev2alpha1.KivePolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "policy-test",
+			Namespace: testNamespaceName,
+		},
+		Spec: kivev2alpha1.KivePolicySpec{
+			AlertVersion: "v1",
+			// Declared twice, the duplicate is reconciled once
+			Traps: []kivev2alpha1.KiveTrap{kiveTrap, kiveTrap},
+		},
+	}
+
+	BeforeAll(func() {
+		pod = createTestPod(podName, NodeName, containerID, ino)
+		Expect(k8sClient.Create(ctx, kivePolicy)).To(Succeed())
+	})
+
+	AfterAll(func() {
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, kivePolicy))).To(Succeed())
+		deleteTestPod(pod)
+	})
+
+	It("Should create a KiveData for the matched container", func() {
+
+		Eventually(func(g Gomega) {
+			kiveDatas := podKiveData(g, podName)
+			g.Expect(kiveDatas).To(HaveLen(1))
+
+			kiveData := kiveDatas[0]
+			g.Expect(kiveData.Spec.InodeNo).To(BeEquivalentTo(ino))
+			g.Expect(kiveData.Spec.Metadata).To(HaveKeyWithValue("team", "kive"))
+			g.Expect(kiveData.Labels).To(HaveKeyWithValue(TrapIDLabel, Not(BeEmpty())))
+			g.Expect(kiveData.Annotations).To(HaveKeyWithValue("path", "/etc/passwd"))
+			g.Expect(kiveData.Annotations).To(HaveKeyWithValue("access", "write"))
+			g.Expect(kiveData.Annotations).To(HaveKeyWithValue("container-id", fakeRuntimeName+"://"+containerID))
+			g.Expect(kiveData.Annotations).To(HaveKeyWithValue("node-name", NodeName))
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("Should report the armed container in the status", func() {
+
+		Eventually(func(g Gomega) {
+			current := &kivev2alpha1.KivePolicy{}
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(kivePolicy), current)).To(Succeed())
+			g.Expect(current.Status.Armed).To(BeEquivalentTo(1))
+			g.Expect(current.Status.Traps).To(HaveLen(1))
+			g.Expect(current.Status.Traps[0].Containers).To(ConsistOf(HaveField("State", kivev2alpha1.KiveTrapFound)))
+			g.Expect(meta.IsStatusConditionTrue(current.Status.Conditions, kivev2alpha1.KivePolicyReady)).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("Should not trap the containers of other nodes", func() {
+
+		// Even if the runtime knows the container, the pods of the
+		// other nodes are not matched
+		otherPod := createTestPod("policy-other-pod", "kive-other-node", "other", ino+1)
+		defer deleteTestPod(otherPod)
+
+		matchTestPod(kivePolicy, otherPod)
+
+		Consistently(func(g Gomega) {
+			g.Expect(podKiveData(g, otherPod.Name)).To(BeEmpty())
+		}, 2*time.Second, interval).Should(Succeed())
+	})
+
+	It("Should report the containers where the file is missing", func() {
+
+		// The trap does not create the file
+		missingPod := createTestPodWithData("policy-missing-pod", NodeName, "missing", container.ContainerData{})
+		defer deleteTestPod(missingPod)
+
+		matchTestPod(kivePolicy, missingPod)
+
+		Eventually(func(g Gomega) {
+			current := &kivev2alpha1.KivePolicy{}
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(kivePolicy), current)).To(Succeed())
+			g.Expect(current.Status.Traps).To(HaveLen(1))
+			g.Expect(current.Status.Traps[0].Containers).To(ContainElement(And(
+				HaveField("Pod", missingPod.Name),
+				HaveField("State", kivev2alpha1.KiveTrapMissing),
+				HaveField("LastError", ContainSubstring("/etc/passwd not found")),
+			)))
+			g.Expect(current.Status.Missing).To(BeEquivalentTo(1))
+			g.Expect(meta.IsStatusConditionTrue(current.Status.Conditions, kivev2alpha1.KivePolicyDegraded)).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+
+		Expect(podKiveData(Default, missingPod.Name)).To(BeEmpty())
+	})
+
+	It("Should delete the KiveData of a deleted KivePolicy", func() {
+
+		Expect(k8sClient.Delete(ctx, kivePolicy)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(podKiveData(g, podName)).To(BeEmpty())
+		}, timeout, interval).Should(Succeed())
+	})
+})
+
+/*
+ *  Add pod to the pods matched by the traps of kivePolicy. The
+ *  update is retried since the reconciler patches the status.
+ */
+func matchTestPod(kivePolicy *kivev2alpha1.KivePolicy, pod *corev1.Pod) {
+
+	Eventually(func(g Gomega) {
+		current := &kivev2alpha1.KivePolicy{}
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(kivePolicy), current)).To(Succeed())
+		for i := range current.Spec.Traps {
+			current.Spec.Traps[i].MatchAny = append(current.Spec.Traps[i].MatchAny, kivev2alpha1.KiveTrapMatch{
+				PodName:   pod.Name,
+				Namespace: testNamespaceName,
+			})
+		}
+		g.Expect(k8sClient.Update(ctx, current)).To(Succeed())
+	}, timeout, interval).Should(Succeed())
+}
+
+var _ = Describe("kiveDataUpToDate", func() {
+
+	desired := func() *kivev2alpha1.KiveData {
+		return &kivev2alpha1.KiveData{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "1-2-kive-data-pod-container",
+				Annotations: map[string]string{"path": "/etc/passwd"},
+				Labels:      map[string]string{TrapIDLabel: "trap"},
+				Finalizers:  []string{KiveDataFinalizerName},
+			},
+			Spec: kivev2alpha1.KiveDataSpec{InodeNo: 1, DevID: 2, Metadata: map[string]string{}},
+		}
+	}
+
+	It("Should not write again an unchanged KiveData", func() {
+
+		previous := desired()
+		previous.ResourceVersion = "42"
+		previous.Spec.Metadata = nil
+		previous.Annotations["other"] = "annotation"
+		Expect(kiveDataUpToDate(*previous, desired())).To(BeTrue())
+	})
+
+	DescribeTable("Should write again a changed KiveData",
+		func(change func(previous *kivev2alpha1.KiveData)) {
+			previous := desired()
+			change(previous)
+			Expect(kiveDataUpToDate(*previous, desired())).To(BeFalse())
+		},
+		Entry("replaced file", func(previous *kivev2alpha1.KiveData) {
+			previous.Name = "3-2-kive-data-pod-container"
+		}),
+		Entry("spec", func(previous *kivev2alpha1.KiveData) {
+			previous.Spec.Metadata = map[string]string{"team": "kive"}
+		}),
+		Entry("annotation", func(previous *kivev2alpha1.KiveData) {
+			previous.Annotations["path"] = "/etc/shadow"
+		}),
+		Entry("label", func(previous *kivev2alpha1.KiveData) {
+			delete(previous.Labels, TrapIDLabel)
+		}),
+		Entry("finalizer", func(previous *kivev2alpha1.KiveData) {
+			previous.Finalizers = nil
+		}),
+		Entry("deletion", func(previous *kivev2alpha1.KiveData) {
+			now := metav1.Now()
+			previous.DeletionTimestamp = &now
+		}),
+	)
+})
@@ -0,0 +1,176 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	// Maximum length of the name of a field manager
+	maxFieldOwnerLength = 128
+)
+
+/*
+ *  Each node applies the status of the containers running on It
+ *  with Its own field manager, so that the entries reported by the
+ *  other nodes are preserved and the ones of this node that are
+ *  not applied anymore are removed.
+ */
+func statusFieldOwner() string {
+
+	fieldOwner := FieldOwnerKiveController + "-" + NodeName
+	if len(fieldOwner) > maxFieldOwnerLength {
+		fieldOwner = fieldOwner[:maxFieldOwnerLength]
+	}
+
+	return fieldOwner
+}
+
+/*
+ *  Apply the status of the traps in the containers of this node,
+ *  then update the conditions from the status reported by all the
+ *  nodes.
+ */
+func (r *KivePolicyReconciler) updateStatus(ctx context.Context, kivePolicy kivev2alpha1.KivePolicy, trapStatuses []kivev2alpha1.KiveTrapStatus) error {
+
+	applied := &kivev2alpha1.KivePolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "KivePolicy",
+			APIVersion: kivev2alpha1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kivePolicy.Name,
+			Namespace: kivePolicy.Namespace,
+		},
+		Status: kivev2alpha1.KivePolicyStatus{
+			Traps: trapStatuses,
+		},
+	}
+	err := r.Status().Patch(ctx, applied, client.Apply, client.ForceOwnership, client.FieldOwner(statusFieldOwner()))
+	if err != nil {
+		return fmt.Errorf("updateStatus Error Apply trap status: %w", err)
+	}
+
+	// The applied object now contains the status of every node
+	orig := applied.DeepCopy()
+	setKivePolicyConditions(applied)
+	if equality.Semantic.DeepEqual(orig.Status, applied.Status) {
+		return nil
+	}
+	err = r.Status().Patch(ctx, applied, client.MergeFrom(orig))
+	if err != nil {
+		return fmt.Errorf("updateStatus Error Patch conditions: %w", err)
+	}
+
+	return nil
+}
+
+/*
+ *  The last time a file of the trap was found replaced in the
+ *  container of containerStatus, as reported in the status of the
+ *  KivePolicy
+ */
+func lastReplaced(kivePolicy kivev2alpha1.KivePolicy, trapID string, containerStatus kivev2alpha1.KiveTrapContainerStatus) *metav1.Time {
+
+	for _, trapStatus := range kivePolicy.Status.Traps {
+		if trapStatus.ID != trapID {
+			continue
+		}
+		for _, previous := range trapStatus.Containers {
+			if previous.Namespace == containerStatus.Namespace && previous.Pod == containerStatus.Pod &&
+				previous.Container == containerStatus.Container {
+				return previous.LastReplaced.DeepCopy()
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+ *  Count the armed and missing containers and set the conditions of
+ *  the KivePolicy. The policy is Ready when every trap is armed in
+ *  at least one container, and Degraded when a trap could not be
+ *  armed in some of the containers It matches.
+ */
+func setKivePolicyConditions(kivePolicy *kivev2alpha1.KivePolicy) {
+
+	// The traps declared more than once are reconciled once
+	trapIDs := map[string]bool{}
+	for _, kiveTrap := range kivePolicy.Spec.Traps {
+		trapID, err := KiveTrapHashID(kiveTrap, kivePolicy.Spec.AlertVersion)
+		if err != nil {
+			continue
+		}
+		trapIDs[trapID] = true
+	}
+
+	armed := int32(0)
+	missing := int32(0)
+	armedTraps := 0
+	for _, trapStatus := range kivePolicy.Status.Traps {
+		trapArmed := false
+		for _, containerStatus := range trapStatus.Containers {
+			if containerStatus.State == kivev2alpha1.KiveTrapMissing || containerStatus.LastError != "" {
+				missing++
+				continue
+			}
+			armed++
+			trapArmed = true
+		}
+		if trapArmed && trapIDs[trapStatus.ID] {
+			armedTraps++
+		}
+	}
+
+	kivePolicy.Status.Armed = armed
+	kivePolicy.Status.Missing = missing
+	kivePolicy.Status.ObservedGeneration = kivePolicy.Generation
+
+	ready := metav1.Condition{
+		Type:               kivev2alpha1.KivePolicyReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "TrapsArmed",
+		Message:            "Every trap is armed in at least one container",
+		ObservedGeneration: kivePolicy.Generation,
+	}
+	if unarmedTraps := len(trapIDs) - armedTraps; unarmedTraps > 0 {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "TrapsNotArmed"
+		ready.Message = fmt.Sprintf("%d of %d traps are not armed in any container", unarmedTraps, len(trapIDs))
+	}
+	meta.SetStatusCondition(&kivePolicy.Status.Conditions, ready)
+
+	degraded := metav1.Condition{
+		Type:               kivev2alpha1.KivePolicyDegraded,
+		Status:             metav1.ConditionFalse,
+		Reason:             "ContainersArmed",
+		Message:            "The traps are armed in every matched container",
+		ObservedGeneration: kivePolicy.Generation,
+	}
+	if missing > 0 {
+		degraded.Status = metav1.ConditionTrue
+		degraded.Reason = "ContainersMissing"
+		degraded.Message = fmt.Sprintf("%d matched containers could not be armed, see status.traps", missing)
+	}
+	meta.SetStatusCondition(&kivePolicy.Status.Conditions, degraded)
+}
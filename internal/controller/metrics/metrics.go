@@ -0,0 +1,191 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	namespace = "kivebpf"
+
+	// Stages of the alert pipeline
+	EventsStage     = "events"
+	DeliveriesStage = "deliveries"
+
+	// Results of a delivery to a sink
+	SuccessResult = "success"
+	FailureResult = "failure"
+)
+
+var (
+	// Number of items waiting in each queue of the alert pipeline
+	PipelineQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "pipeline",
+			Name:      "queue_depth",
+			Help:      "Number of items waiting in a queue of the alert pipeline.",
+		},
+		[]string{"stage"},
+	)
+
+	// Capacity of each queue of the alert pipeline
+	PipelineQueueCapacity = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "pipeline",
+			Name:      "queue_capacity",
+			Help:      "Maximum number of items in a queue of the alert pipeline.",
+		},
+		[]string{"stage"},
+	)
+
+	// eBPF events dropped because the events queue was full
+	PipelineDroppedEvents = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "pipeline",
+			Name:      "dropped_events_total",
+			Help:      "Number of eBPF events dropped because the events queue of the alert pipeline was full.",
+		},
+	)
+)
+
+var (
+	// KiveAlerts generated from the eBPF events. The traps are
+	// labeled by ID and not by path, since a recursive, glob or regex
+	// trap has a path for each of Its files
+	AlertsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "alerts_total",
+			Help:      "Number of KiveAlerts generated, by policy, trap ID, namespace of the pod and access mask.",
+		},
+		[]string{"policy", "trap", "namespace", "mask"},
+	)
+
+	// KiveAlerts not sent because of the dedup window or the rate
+	// limit of their trap
+	AlertsSuppressed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "alerts_suppressed_total",
+			Help:      "Number of KiveAlerts not sent, by policy, trap ID and reason (dedup or rate-limit).",
+		},
+		[]string{"policy", "trap", "reason"},
+	)
+
+	// Deliveries of KiveAlerts to the sinks
+	SinkDeliveriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sink",
+			Name:      "deliveries_total",
+			Help:      "Number of KiveAlerts sent to a sink, by sink type and result (success or failure).",
+		},
+		[]string{"type", "result"},
+	)
+
+	// Time spent delivering a KiveAlert to a sink, retries included
+	SinkDeliveryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "sink",
+			Name:      "delivery_duration_seconds",
+			Help:      "Time spent sending a KiveAlert to a sink, retries included.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		},
+		[]string{"type"},
+	)
+
+	// Errors while reading the eBPF ring buffer
+	RingbufReadErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "ebpf",
+			Name:      "ringbuf_read_errors_total",
+			Help:      "Number of errors while reading or parsing the eBPF ring buffer.",
+		},
+	)
+
+	// eBPF events not sent because the ring buffer was full
+	RingbufLostEvents = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "ebpf",
+			Name:      "ringbuf_lost_events_total",
+			Help:      "Number of eBPF events the eBPF program could not send because the ring buffer was full.",
+		},
+	)
+
+	// eBPF events whose file is not in any KiveData
+	KiveDataMisses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "ebpf",
+			Name:      "kivedata_misses_total",
+			Help:      "Number of eBPF events for which no KiveData was found.",
+		},
+	)
+
+	// Entries in the traced_inodes eBPF map
+	TracedInodes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "ebpf",
+			Name:      "traced_inodes",
+			Help:      "Number of entries in the traced_inodes eBPF map.",
+		},
+	)
+
+	// Capacity of the traced_inodes eBPF map
+	TracedInodesMax = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "ebpf",
+			Name:      "traced_inodes_max",
+			Help:      "Maximum number of entries in the traced_inodes eBPF map.",
+		},
+	)
+
+	// Backend of the loaded eBPF programs
+	EbpfBackend = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "ebpf",
+			Name:      "backend_info",
+			Help:      "Set to 1 for the backend of the loaded eBPF programs.",
+		},
+		[]string{"backend"},
+	)
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		PipelineQueueDepth,
+		PipelineQueueCapacity,
+		PipelineDroppedEvents,
+		AlertsTotal,
+		AlertsSuppressed,
+		SinkDeliveriesTotal,
+		SinkDeliveryDuration,
+		RingbufReadErrors,
+		RingbufLostEvents,
+		KiveDataMisses,
+		TracedInodes,
+		TracedInodesMax,
+		EbpfBackend,
+	)
+}
@@ -13,42 +13,472 @@
 package controller
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
-	"net/http"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
 
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	"github.com/cilium/ebpf/ringbuf"
+	corev1 "k8s.io/api/core/v1"
 
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+	container "github.com/San7o/kivebpf/internal/controller/container"
 	kivebpf "github.com/San7o/kivebpf/internal/controller/ebpf"
+	events "github.com/San7o/kivebpf/internal/controller/events"
+	metrics "github.com/San7o/kivebpf/internal/controller/metrics"
+	sink "github.com/San7o/kivebpf/internal/controller/sink"
 	logger "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-func Output(client client.Reader) {
+// What the reader does when the events queue is full
+type OverflowPolicy = string
+
+const (
+	// Wait for space in the queue. The eBPF ring buffer fills up and
+	// the kernel drops new events
+	OverflowBlock OverflowPolicy = "block"
+	// Drop the event that was just read
+	OverflowDropNewest OverflowPolicy = "drop-newest"
+	// Drop the oldest event in the queue to make space
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+
+	DefaultPipelineQueueSize       = 4096
+	DefaultPipelineEnrichWorkers   = 2
+	DefaultPipelineDeliveryWorkers = 4
+	DefaultLostEventsInterval      = time.Second
+)
+
+// An alert ready to be delivered
+type delivery struct {
+	alert    kivev2alpha1.KiveAlert
+	kiveData kivev2alpha1.KiveData
+}
+
+// The alert pipeline reads the eBPF events and turns them into
+// delivered KiveAlerts in three stages, so that a slow sink does not
+// stop the ring buffer from being drained:
+//   - a reader goroutine moves the events from the ring buffer to a
+//     bounded events queue, applying Overflow when It is full.
+//   - EnrichWorkers goroutines generate the KiveAlerts, aggregate or
+//     drop them according to the dedup window and the rate limit of
+//     their trap, and put them in a bounded deliveries queue.
+//   - DeliveryWorkers goroutines send the KiveAlerts to their sinks.
+//
+// Every LostEventsInterval, the events the eBPF program could not
+// send because the ring buffer was full are counted and reported
+// with a KiveAlert to the sinks of every KivePolicy armed on the
+// node.
+type AlertPipeline struct {
+	QueueSize          int
+	Overflow           OverflowPolicy
+	EnrichWorkers      int
+	DeliveryWorkers    int
+	LostEventsInterval time.Duration
+	// (optional) Records an Event on the KivePolicy and the Pod of
+	// each alert
+	Recorder *events.Recorder
+	// Reads the accesses and counts the lost events. If nil,
+	// kivebpf.DefaultTracer is used
+	Tracer kivebpf.Tracer
+	// Finds the KiveData of the accesses, kept in sync with the
+	// tracer by the KiveData reconciler. If nil, kivebpf.DefaultIndex
+	// is used
+	Index *kivebpf.InodeIndex
+	// Procfs where the processes of the accesses are read, in order.
+	// If empty, container.DefaultProcMountpoints are used
+	ProcMountpoints []string
+}
+
+func (self *AlertPipeline) tracer() kivebpf.Tracer {
+
+	if self.Tracer == nil {
+		return kivebpf.DefaultTracer
+	}
+
+	return self.Tracer
+}
+
+func (self *AlertPipeline) index() *kivebpf.InodeIndex {
+
+	if self.Index == nil {
+		return kivebpf.DefaultIndex
+	}
+
+	return self.Index
+}
+
+func (self *AlertPipeline) procMountpoints() []string {
+
+	if len(self.ProcMountpoints) == 0 {
+		return container.DefaultProcMountpoints()
+	}
+
+	return self.ProcMountpoints
+}
+
+func IsOverflowPolicySupported(policy OverflowPolicy) bool {
+	return policy == OverflowBlock || policy == OverflowDropNewest || policy == OverflowDropOldest
+}
+
+/*
+ *  Run the pipeline until the ring buffer is closed or ctx is done.
+ *  Events already in the queues are still delivered.
+ */
+func (self *AlertPipeline) Run(ctx context.Context) {
+
+	log := logger.FromContext(ctx)
+
+	queueSize := self.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultPipelineQueueSize
+	}
+	enrichWorkers := self.EnrichWorkers
+	if enrichWorkers <= 0 {
+		enrichWorkers = DefaultPipelineEnrichWorkers
+	}
+	deliveryWorkers := self.DeliveryWorkers
+	if deliveryWorkers <= 0 {
+		deliveryWorkers = DefaultPipelineDeliveryWorkers
+	}
+	lostEventsInterval := self.LostEventsInterval
+	if lostEventsInterval <= 0 {
+		lostEventsInterval = DefaultLostEventsInterval
+	}
+	overflow := self.Overflow
+	if !IsOverflowPolicySupported(overflow) {
+		log.Info(fmt.Sprintf("Alert pipeline: overflow policy %s is not supported, defaulting to %s", overflow, OverflowBlock))
+		overflow = OverflowBlock
+	}
+
+	events := make(chan kivebpf.BpfLogData, queueSize)
+	deliveries := make(chan delivery, queueSize)
+	metrics.PipelineQueueCapacity.WithLabelValues(metrics.EventsStage).Set(float64(queueSize))
+	metrics.PipelineQueueCapacity.WithLabelValues(metrics.DeliveriesStage).Set(float64(queueSize))
+
+	go self.read(ctx, events, overflow)
+
+	dedup := NewDeduplicator()
+	var enrichWg sync.WaitGroup
+	for range enrichWorkers {
+		enrichWg.Add(1)
+		go func() {
+			defer enrichWg.Done()
+			self.enrich(ctx, events, deliveries, dedup)
+		}()
+	}
+
+	// Send the aggregated alerts when their dedup window ends
+	tickersDone := make(chan struct{})
+	var tickersWg sync.WaitGroup
+	tickersWg.Add(1)
+	go func() {
+		defer tickersWg.Done()
+		ticker := time.NewTicker(dedupFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-tickersDone:
+				return
+			case now := <-ticker.C:
+				for _, item := range dedup.Flush(now, false) {
+					deliveries <- item
+				}
+			}
+		}
+	}()
+
+	// Report the events lost in the kernel
+	tickersWg.Add(1)
+	go func() {
+		defer tickersWg.Done()
+		ticker := time.NewTicker(lostEventsInterval)
+		defer ticker.Stop()
+		reported := uint64(0)
+		for {
+			select {
+			case <-tickersDone:
+				return
+			case <-ticker.C:
+				reported = self.reportLostEvents(ctx, deliveries, reported)
+			}
+		}
+	}()
+
+	var deliveryWg sync.WaitGroup
+	for range deliveryWorkers {
+		deliveryWg.Add(1)
+		go func() {
+			defer deliveryWg.Done()
+			self.deliver(ctx, deliveries)
+		}()
+	}
+
+	enrichWg.Wait()
+	close(tickersDone)
+	tickersWg.Wait()
+	for _, item := range dedup.Flush(time.Now(), true) {
+		deliveries <- item
+	}
+	close(deliveries)
+	deliveryWg.Wait()
+	log.Info("Alert pipeline stopped")
+}
+
+func (self *AlertPipeline) read(ctx context.Context, events chan kivebpf.BpfLogData, overflow OverflowPolicy) {
 
-	ctx := context.Background()
 	log := logger.FromContext(ctx)
+	defer close(events)
+
+	tracer := self.tracer()
+	if !tracer.IsLoaded() {
+		log.Error(fmt.Errorf("Ringbuffer not inizialized"), "Output Error Read events")
+		return
+	}
+
+	for ctx.Err() == nil {
 
-	for {
-		alert, err := kivebpf.ReadAlert(ctx, client)
+		data, err := tracer.ReadEvent() // Hangs
 		if err != nil {
-			log.Error(err, "Output Error Read alert")
+			if errors.Is(err, ringbuf.ErrClosed) {
+				return
+			}
+			metrics.RingbufReadErrors.Inc()
+			log.Error(err, "Output Error Read eBPF data")
 			continue
 		}
 
-		jsonAlert, err := json.Marshal(alert)
-		if err != nil {
-			log.Error(err, "Output Error Json Marshal")
+		switch overflow {
+		case OverflowDropNewest:
+			select {
+			case events <- data:
+			default:
+				metrics.PipelineDroppedEvents.Inc()
+			}
+		case OverflowDropOldest:
+			for sent := false; !sent; {
+				select {
+				case events <- data:
+					sent = true
+				default:
+					select {
+					case <-events:
+						metrics.PipelineDroppedEvents.Inc()
+					default:
+					}
+				}
+			}
+		default:
+			select {
+			case events <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+		metrics.PipelineQueueDepth.WithLabelValues(metrics.EventsStage).Set(float64(len(events)))
+	}
+}
+
+func (self *AlertPipeline) enrich(ctx context.Context, events chan kivebpf.BpfLogData, deliveries chan delivery, dedup *Deduplicator) {
+
+	log := logger.FromContext(ctx)
+
+	for data := range events {
+		metrics.PipelineQueueDepth.WithLabelValues(metrics.EventsStage).Set(float64(len(events)))
+
+		// The traps that overlap on the file have a KiveData each, an
+		// alert is generated for each trap the access matched
+		kiveDatas := self.index().Get(kivebpf.BpfMapKey{Inode: data.Ino, Dev: data.Dev})
+		if len(kiveDatas) == 0 {
+			metrics.KiveDataMisses.Inc()
+			log.Error(errors.New("eBPF data received but no corresponding KiveData was found"), "Output Error Generate alert")
+			continue
+		}
+
+		var process *container.Process
+		for _, kiveData := range kiveDatas {
+
+			matches, err := KiveDataMatches(kiveData, data)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("Output Error Match KiveData %s", kiveData.Name))
+				continue
+			}
+			if !matches {
+				continue
+			}
+
+			// The process is read once for all the alerts
+			if process == nil {
+				eventProcess := kivebpf.ReadEventProcess(ctx, self.procMountpoints(), data)
+				process = &eventProcess
+			}
+
+			alert := kivebpf.GenerateAlert(ctx, kiveData, *process, data)
+			metrics.AlertsTotal.WithLabelValues(alert.PolicyName, kiveData.Labels[TrapIDLabel],
+				alert.Pod.Namespace, strconv.FormatInt(int64(alert.Metadata.Mask), 10)).Inc()
+
+			window, err := KiveDataDedupWindow(kiveData)
+			if err != nil {
+				log.Error(err, "Output Error Get dedup window")
+			}
+			rateLimit, err := KiveDataRateLimit(kiveData)
+			if err != nil {
+				log.Error(err, "Output Error Get rate limit")
+			}
+
+			for _, item := range dedup.Add(delivery{alert: alert, kiveData: kiveData}, window, rateLimit, time.Now()) {
+				deliveries <- item
+			}
+		}
+		metrics.PipelineQueueDepth.WithLabelValues(metrics.DeliveriesStage).Set(float64(len(deliveries)))
+	}
+}
+
+/*
+ *  Send a KiveAlert for the events lost since reported, the number
+ *  of lost events already reported. Returns the lost events
+ *  reported so far.
+ */
+func (self *AlertPipeline) reportLostEvents(ctx context.Context, deliveries chan delivery, reported uint64) uint64 {
+
+	log := logger.FromContext(ctx)
+
+	lost, err := self.tracer().CountLostEvents()
+	if err != nil {
+		log.Error(err, "Output Error Count lost events")
+		return reported
+	}
+	if lost <= reported {
+		return reported
+	}
+
+	newlyLost := lost - reported
+	metrics.RingbufLostEvents.Add(float64(newlyLost))
+	log.Info("eBPF events lost because the ring buffer is full", "lost", newlyLost)
+
+	for _, kiveData := range lostEventsTargets(self.index().List()) {
+		deliveries <- delivery{
+			alert:    kivebpf.GenerateLostAlert(kiveData, newlyLost),
+			kiveData: kiveData,
+		}
+	}
+
+	return lost
+}
+
+/*
+ *  One KiveData for each KivePolicy and set of sinks among
+ *  kiveDatas, so that every sink of the armed traps receives the
+ *  lost events once per KivePolicy.
+ */
+func lostEventsTargets(kiveDatas []kivev2alpha1.KiveData) []kivev2alpha1.KiveData {
+
+	type target struct {
+		namespace string
+		name      string
+		sinks     string
+		callback  string
+	}
+
+	seen := map[target]bool{}
+	targets := []kivev2alpha1.KiveData{}
+	for _, kiveData := range kiveDatas {
+		key := target{
+			namespace: kiveData.Annotations["kive-policy-namespace"],
+			name:      kiveData.Annotations["kive-policy-name"],
+			sinks:     kiveData.Annotations["sinks"],
+			callback:  kiveData.Annotations["callback"],
+		}
+		if seen[key] {
 			continue
 		}
-		if alert.Metadata.Callback != "" {
-			_, err := http.Post(alert.Metadata.Callback, "application/json", bufio.NewReader(bytes.NewReader(jsonAlert)))
+		seen[key] = true
+		targets = append(targets, kiveData)
+	}
+
+	return targets
+}
+
+func (self *AlertPipeline) deliver(ctx context.Context, deliveries chan delivery) {
+
+	log := logger.FromContext(ctx)
+
+	for item := range deliveries {
+		metrics.PipelineQueueDepth.WithLabelValues(metrics.DeliveriesStage).Set(float64(len(deliveries)))
+
+		self.recordAlert(item)
+
+		kiveSinks, err := KiveDataSinks(item.kiveData)
+		if err != nil {
+			log.Error(err, "Output Error Get sinks")
+		}
+
+		if len(kiveSinks) == 0 {
+			jsonAlert, err := json.Marshal(item.alert)
 			if err != nil {
-				log.Error(err, "Output Error Post Callback")
+				log.Error(err, "Output Error Json Marshal")
+				continue
+			}
+			if item.alert.Lost > 0 {
+				log.Info("Events Lost", "KiveAlert", string(jsonAlert))
+				continue
 			}
-		} else {
 			log.Info("Access Detected", "KiveAlert", string(jsonAlert))
+			continue
+		}
+
+		if err := sink.Dispatch(ctx, kiveSinks, item.alert); err != nil {
+			log.Error(err, "Output Error Dispatch alert")
 		}
 	}
 }
+
+func (self *AlertPipeline) recordAlert(item delivery) {
+
+	if self.Recorder == nil {
+		return
+	}
+
+	kivePolicyRef, podRef := KiveDataEventReferences(item.kiveData)
+	if item.alert.Lost > 0 {
+		self.Recorder.Eventf(kivePolicyRef, corev1.EventTypeWarning, events.EventsLostReason,
+			"%d eBPF events lost on node %s because the ring buffer was full, accesses may not have been alerted",
+			item.alert.Lost, item.alert.Node.Name)
+		return
+	}
+	reason, verb := alertEventReason(item.alert)
+	self.Recorder.Eventf(kivePolicyRef, corev1.EventTypeWarning, reason,
+		"File %s %s by pid %d (%s) in container %s of pod %s/%s", item.alert.Metadata.Path, verb,
+		item.alert.Process.Pid, item.alert.Process.Binary, item.alert.Pod.Container.Name,
+		item.alert.Pod.Namespace, item.alert.Pod.Name)
+	self.Recorder.Eventf(podRef, corev1.EventTypeWarning, reason,
+		"File %s %s by pid %d (%s) in container %s", item.alert.Metadata.Path, verb,
+		item.alert.Process.Pid, item.alert.Process.Binary, item.alert.Pod.Container.Name)
+}
+
+/*
+ *  Reason and verb of the Events of an alert, depending on the
+ *  operation on the file and on whether It was denied.
+ */
+func alertEventReason(alert kivev2alpha1.KiveAlert) (string, string) {
+
+	if alert.Blocked {
+		return events.FileAccessDeniedReason, "access denied"
+	}
+	if alert.WouldBlock {
+		return events.FileAccessedReason, "accessed, would have been denied,"
+	}
+
+	switch alert.Metadata.Operation {
+	case kivev2alpha1.KiveOperationUnlink:
+		return events.FileTamperedReason, "deleted"
+	case kivev2alpha1.KiveOperationRename:
+		return events.FileTamperedReason, "renamed"
+	case kivev2alpha1.KiveOperationSetattr:
+		return events.FileTamperedReason, "modified"
+	}
+
+	return events.FileAccessedReason, "accessed"
+}
@@ -0,0 +1,215 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	// Headers added to the requests of a webhook sink with an HMAC
+	// secret. The signature is computed over
+	// "<timestamp>.<nonce>.<body>" and the receiver should reject
+	// requests with a stale timestamp or an already seen nonce.
+	SignatureHeader = "X-Kive-Signature"
+	TimestampHeader = "X-Kive-Timestamp"
+	NonceHeader     = "X-Kive-Nonce"
+	// Prefix of the value of SignatureHeader
+	SignaturePrefix = "sha256="
+
+	DefaultHMACSecretKey = "hmac-key"
+
+	// How long a Secret is used before being fetched again
+	secretCacheTTL = time.Minute
+)
+
+/*
+ *  Compute the value of SignatureHeader for a request body.
+ */
+func SignAlert(key []byte, timestamp string, nonce string, body []byte) string {
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+ *  The HMAC key in the data of a Secret. The whitespace around It is
+ *  not part of the key, like the newline that ends the files of
+ *  kubectl create secret --from-file. The receivers must trim It too.
+ */
+func HMACKey(data []byte) []byte {
+	return bytes.TrimSpace(data)
+}
+
+type cachedSecret struct {
+	secret  *corev1.Secret
+	fetched time.Time
+}
+
+type cachedClient struct {
+	client          *http.Client
+	resourceVersion string
+}
+
+// Secrets and mTLS clients used by a webhook sink. Secrets are
+// fetched at most once every secretCacheTTL so that a rotated key is
+// eventually picked up without hitting the API server on each alert.
+type webhookAuth struct {
+	mutex   sync.Mutex
+	secrets map[types.NamespacedName]cachedSecret
+	clients map[types.NamespacedName]cachedClient
+}
+
+func (self *webhookAuth) getSecret(ctx context.Context, reader client.Reader, key types.NamespacedName) (*corev1.Secret, error) {
+
+	if reader == nil {
+		return nil, fmt.Errorf("no client to read Secret %s", key)
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.secrets == nil {
+		self.secrets = map[types.NamespacedName]cachedSecret{}
+	}
+
+	cached, ok := self.secrets[key]
+	if ok && time.Since(cached.fetched) < secretCacheTTL {
+		return cached.secret, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := reader.Get(ctx, key, secret); err != nil {
+		return nil, fmt.Errorf("Get Secret %s: %w", key, err)
+	}
+	self.secrets[key] = cachedSecret{secret: secret, fetched: time.Now()}
+
+	return secret, nil
+}
+
+/*
+ *  Add the signature headers to req if the sink has an HMAC secret.
+ */
+func (self *webhookAuth) sign(ctx context.Context, reader client.Reader, auth *kivev2alpha1.KiveSinkAuth, req *http.Request, body []byte) error {
+
+	if auth == nil || auth.HMACSecretRef == nil {
+		return nil
+	}
+
+	secret, err := self.getSecret(ctx, reader, types.NamespacedName{Namespace: auth.Namespace, Name: auth.HMACSecretRef.Name})
+	if err != nil {
+		return err
+	}
+
+	secretKey := auth.HMACSecretRef.Key
+	if secretKey == "" {
+		secretKey = DefaultHMACSecretKey
+	}
+	hmacKey := HMACKey(secret.Data[secretKey])
+	if len(hmacKey) == 0 {
+		return fmt.Errorf("Secret %s/%s has no key %s", auth.Namespace, auth.HMACSecretRef.Name, secretKey)
+	}
+
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return fmt.Errorf("Generate nonce: %w", err)
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, nonce)
+	req.Header.Set(SignatureHeader, SignAlert(hmacKey, timestamp, nonce, body))
+
+	return nil
+}
+
+/*
+ *  Get the HTTP client presenting the certificate of the TLS secret
+ *  of the sink, or fallback if the sink does not use mTLS. Clients
+ *  are rebuilt when the Secret changes.
+ */
+func (self *webhookAuth) httpClient(ctx context.Context, reader client.Reader, auth *kivev2alpha1.KiveSinkAuth, fallback *http.Client) (*http.Client, error) {
+
+	if auth == nil || auth.TLSSecretName == "" {
+		return fallback, nil
+	}
+
+	key := types.NamespacedName{Namespace: auth.Namespace, Name: auth.TLSSecretName}
+	secret, err := self.getSecret(ctx, reader, key)
+	if err != nil {
+		return nil, err
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.clients == nil {
+		self.clients = map[types.NamespacedName]cachedClient{}
+	}
+
+	cached, ok := self.clients[key]
+	if ok && cached.resourceVersion == secret.ResourceVersion {
+		return cached.client, nil
+	}
+
+	cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("Load client certificate from Secret %s: %w", key, err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if caBundle, ok := secret.Data["ca.crt"]; ok && len(caBundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("Parse ca.crt from Secret %s", key)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	httpClient := &http.Client{
+		Timeout:   fallback.Timeout,
+		Transport: transport,
+	}
+	self.clients[key] = cachedClient{client: httpClient, resourceVersion: secret.ResourceVersion}
+
+	return httpClient, nil
+}
@@ -0,0 +1,213 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package sink
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	testTimestamp = "1700000000"
+	testNonce     = "00112233445566778899aabbccddeeff"
+	testBody      = `{"kive-policy-name":"p"}`
+)
+
+func TestSignAlert(t *testing.T) {
+
+	// Computed independently with python's hmac module
+	tests := []struct {
+		name      string
+		key       string
+		timestamp string
+		nonce     string
+		body      string
+		want      string
+	}{
+		{
+			name:      "alert",
+			key:       "secret",
+			timestamp: testTimestamp,
+			nonce:     testNonce,
+			body:      testBody,
+			want:      "sha256=a534f674307b7f821221adbe30e5653853268f4a9d8dc2c77d69f6ae8dc7636e",
+		},
+		{
+			name:      "empty",
+			key:       "",
+			timestamp: "0",
+			nonce:     "",
+			body:      "",
+			want:      "sha256=586ae560b48dfb3e5a894913081af6b705748ee135f10f06827f7310745ea9c3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := SignAlert([]byte(test.key), test.timestamp, test.nonce, []byte(test.body))
+			if got != test.want {
+				t.Errorf("SignAlert = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSignAlertCoversEveryField(t *testing.T) {
+
+	signature := SignAlert([]byte("secret"), testTimestamp, testNonce, []byte(testBody))
+
+	tests := []struct {
+		name      string
+		key       string
+		timestamp string
+		nonce     string
+		body      string
+	}{
+		{"tampered body", "secret", testTimestamp, testNonce, `{"kive-policy-name":"q"}`},
+		{"wrong key", "not-the-secret", testTimestamp, testNonce, testBody},
+		{"other timestamp", "secret", "1700000001", testNonce, testBody},
+		{"other nonce", "secret", testTimestamp, "ffeeddccbbaa99887766554433221100", testBody},
+		// The separators keep the fields from being shifted
+		{"shifted fields", "secret", testTimestamp + "." + testNonce, "", testBody},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := SignAlert([]byte(test.key), test.timestamp, test.nonce, []byte(test.body))
+			if got == signature {
+				t.Errorf("SignAlert does not depend on the %s", test.name)
+			}
+		})
+	}
+}
+
+func TestWebhookAuthSign(t *testing.T) {
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "hmac", Namespace: "kive-test"},
+		Data: map[string][]byte{
+			DefaultHMACSecretKey: []byte("secret"),
+			"other":              []byte("other-secret"),
+			// Like the files of kubectl create secret --from-file
+			"newline": []byte("newline-secret\n"),
+			"blank":   []byte(" \n"),
+		},
+	}
+	reader := fake.NewClientBuilder().WithObjects(secret).Build()
+
+	tests := []struct {
+		name    string
+		auth    *kivev2alpha1.KiveSinkAuth
+		key     string
+		wantErr bool
+	}{
+		{
+			name: "no auth",
+			auth: nil,
+		},
+		{
+			name: "default key",
+			auth: &kivev2alpha1.KiveSinkAuth{
+				Namespace:     "kive-test",
+				HMACSecretRef: &kivev2alpha1.KiveSecretKeyRef{Name: "hmac"},
+			},
+			key: "secret",
+		},
+		{
+			name: "other key",
+			auth: &kivev2alpha1.KiveSinkAuth{
+				Namespace:     "kive-test",
+				HMACSecretRef: &kivev2alpha1.KiveSecretKeyRef{Name: "hmac", Key: "other"},
+			},
+			key: "other-secret",
+		},
+		{
+			name: "newline-terminated key",
+			auth: &kivev2alpha1.KiveSinkAuth{
+				Namespace:     "kive-test",
+				HMACSecretRef: &kivev2alpha1.KiveSecretKeyRef{Name: "hmac", Key: "newline"},
+			},
+			key: "newline-secret",
+		},
+		{
+			name: "blank key",
+			auth: &kivev2alpha1.KiveSinkAuth{
+				Namespace:     "kive-test",
+				HMACSecretRef: &kivev2alpha1.KiveSecretKeyRef{Name: "hmac", Key: "blank"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing key",
+			auth: &kivev2alpha1.KiveSinkAuth{
+				Namespace:     "kive-test",
+				HMACSecretRef: &kivev2alpha1.KiveSecretKeyRef{Name: "hmac", Key: "missing"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing secret",
+			auth: &kivev2alpha1.KiveSinkAuth{
+				Namespace:     "kive-test",
+				HMACSecretRef: &kivev2alpha1.KiveSecretKeyRef{Name: "missing"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			req, err := http.NewRequest(http.MethodPost, "http://callback", strings.NewReader(testBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = (&webhookAuth{}).sign(context.Background(), reader, test.auth, req, []byte(testBody))
+			if test.wantErr {
+				if err == nil {
+					t.Error("sign succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.auth == nil {
+				if req.Header.Get(SignatureHeader) != "" {
+					t.Error("unsigned sink got a signature")
+				}
+				return
+			}
+
+			timestamp := req.Header.Get(TimestampHeader)
+			nonce := req.Header.Get(NonceHeader)
+			if timestamp == "" || len(nonce) != 32 {
+				t.Fatalf("timestamp %q and nonce %q not set", timestamp, nonce)
+			}
+			want := SignAlert([]byte(test.key), timestamp, nonce, []byte(testBody))
+			if got := req.Header.Get(SignatureHeader); got != want {
+				t.Errorf("signature %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	deadLetterSuffix = ".json"
+)
+
+// An alert that could not be delivered, together with the sink It
+// was meant for
+type DeadLetter struct {
+	Sink  kivev2alpha1.KiveSink  `json:"sink"`
+	Alert kivev2alpha1.KiveAlert `json:"alert"`
+}
+
+// On-disk spool of undelivered alerts. Each alert is stored in Its
+// own file inside Dir, named after the time It was spooled so that
+// alerts are replayed in order. When MaxEntries is reached, the
+// oldest alerts are dropped.
+type DeadLetterQueue struct {
+	Dir        string
+	MaxEntries int
+
+	mutex       sync.Mutex
+	replayMutex sync.Mutex
+	counter     uint64
+}
+
+func NewDeadLetterQueue(dir string, maxEntries int) (*DeadLetterQueue, error) {
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("NewDeadLetterQueue Error Create directory %s: %w", dir, err)
+	}
+
+	return &DeadLetterQueue{
+		Dir:        dir,
+		MaxEntries: maxEntries,
+	}, nil
+}
+
+func (self *DeadLetterQueue) Push(kiveSink kivev2alpha1.KiveSink, alert kivev2alpha1.KiveAlert) error {
+
+	jsonLetter, err := json.Marshal(DeadLetter{Sink: kiveSink, Alert: alert})
+	if err != nil {
+		return fmt.Errorf("DeadLetterQueue Push Error Json Marshal: %w", err)
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	entries, err := self.entries()
+	if err != nil {
+		return err
+	}
+	for len(entries) >= self.MaxEntries && len(entries) > 0 {
+		if err := os.Remove(filepath.Join(self.Dir, entries[0])); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("DeadLetterQueue Push Error Drop oldest alert: %w", err)
+		}
+		entries = entries[1:]
+	}
+
+	self.counter++
+	name := fmt.Sprintf("%020d-%010d%s", time.Now().UnixNano(), self.counter, deadLetterSuffix)
+
+	// Write to a temporary file first so that a crash never leaves
+	// a partial alert in the spool
+	tmp := filepath.Join(self.Dir, "."+name)
+	if err := os.WriteFile(tmp, jsonLetter, 0600); err != nil {
+		return fmt.Errorf("DeadLetterQueue Push Error Write: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(self.Dir, name)); err != nil {
+		return fmt.Errorf("DeadLetterQueue Push Error Rename: %w", err)
+	}
+
+	return nil
+}
+
+/*
+ *  Try to deliver again every spooled alert with send. Alerts that
+ *  are delivered are removed from the spool. Once delivery to an
+ *  endpoint fails, the remaining alerts for that endpoint are kept
+ *  for the next replay without being tried.
+ *
+ *  The spool is not locked while the alerts are sent, so that Push
+ *  does not wait for slow endpoints. The alerts pushed in the
+ *  meantime are replayed the next time.
+ */
+func (self *DeadLetterQueue) Replay(ctx context.Context, send func(ctx context.Context, letter DeadLetter) error) (int, error) {
+
+	// Two replays would send the same alerts twice
+	self.replayMutex.Lock()
+	defer self.replayMutex.Unlock()
+
+	letters, err := self.snapshot()
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	unreachable := map[string]bool{}
+	for _, letter := range letters {
+
+		if ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+
+		if unreachable[letter.Sink.URL] {
+			continue
+		}
+
+		if err := send(ctx, letter.DeadLetter); err != nil {
+			unreachable[letter.Sink.URL] = true
+			continue
+		}
+
+		if err := self.remove(letter.entry); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+// A spooled alert and the name of Its file
+type spooledLetter struct {
+	DeadLetter
+	entry string
+}
+
+/*
+ *  Read the spooled alerts, oldest first. The corrupted alerts are
+ *  removed, since they would stay in the spool forever.
+ */
+func (self *DeadLetterQueue) snapshot() ([]spooledLetter, error) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	entries, err := self.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	letters := []spooledLetter{}
+	for _, entry := range entries {
+
+		path := filepath.Join(self.Dir, entry)
+		jsonLetter, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("DeadLetterQueue Replay Error Read %s: %w", entry, err)
+		}
+
+		letter := spooledLetter{entry: entry}
+		if err := json.Unmarshal(jsonLetter, &letter.DeadLetter); err != nil {
+			os.Remove(path)
+			continue
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
+
+/*
+ *  Remove a delivered alert. It may have already been dropped by Push
+ *  while It was sent.
+ */
+func (self *DeadLetterQueue) remove(entry string) error {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if err := os.Remove(filepath.Join(self.Dir, entry)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("DeadLetterQueue Replay Error Remove %s: %w", entry, err)
+	}
+
+	return nil
+}
+
+func (self *DeadLetterQueue) Len() (int, error) {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	entries, err := self.entries()
+	return len(entries), err
+}
+
+// Sorted names of the spooled alerts, oldest first
+func (self *DeadLetterQueue) entries() ([]string, error) {
+
+	dirEntries, err := os.ReadDir(self.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("DeadLetterQueue Error Read directory %s: %w", self.Dir, err)
+	}
+
+	entries := []string{}
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, deadLetterSuffix) {
+			continue
+		}
+		if _, err := strconv.ParseUint(strings.SplitN(name, "-", 2)[0], 10, 64); err != nil {
+			continue
+		}
+		entries = append(entries, name)
+	}
+	sort.Strings(entries)
+
+	return entries, nil
+}
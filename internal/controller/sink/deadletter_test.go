@@ -0,0 +1,108 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package sink
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+func TestDeadLetterQueueReplay(t *testing.T) {
+
+	queue, err := NewDeadLetterQueue(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up := kivev2alpha1.KiveSink{Type: WebhookSinkType, URL: "http://up"}
+	down := kivev2alpha1.KiveSink{Type: WebhookSinkType, URL: "http://down"}
+	for _, kiveSink := range []kivev2alpha1.KiveSink{up, down, up, down} {
+		if err := queue.Push(kiveSink, kivev2alpha1.KiveAlert{PolicyName: kiveSink.URL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sent := map[string]int{}
+	delivered, err := queue.Replay(context.Background(), func(ctx context.Context, letter DeadLetter) error {
+		sent[letter.Sink.URL]++
+		if letter.Sink.URL == down.URL {
+			return errors.New("unreachable")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if delivered != 2 {
+		t.Errorf("delivered %d alerts, want 2", delivered)
+	}
+	// The second alert to the unreachable endpoint is not tried
+	if sent[up.URL] != 2 || sent[down.URL] != 1 {
+		t.Errorf("sent %v, want 2 to %s and 1 to %s", sent, up.URL, down.URL)
+	}
+	if length, _ := queue.Len(); length != 2 {
+		t.Errorf("%d alerts left in the spool, want 2", length)
+	}
+}
+
+func TestDeadLetterQueuePushDuringReplay(t *testing.T) {
+
+	queue, err := NewDeadLetterQueue(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kiveSink := kivev2alpha1.KiveSink{Type: WebhookSinkType, URL: "http://slow"}
+	if err := queue.Push(kiveSink, kivev2alpha1.KiveAlert{}); err != nil {
+		t.Fatal(err)
+	}
+
+	sending := make(chan struct{})
+	pushed := make(chan struct{})
+	replayed := make(chan error)
+	go func() {
+		_, err := queue.Replay(context.Background(), func(ctx context.Context, letter DeadLetter) error {
+			close(sending)
+			<-pushed
+			return nil
+		})
+		replayed <- err
+	}()
+
+	// The endpoint does not answer until the alert is pushed
+	<-sending
+	go func() {
+		if err := queue.Push(kiveSink, kivev2alpha1.KiveAlert{}); err != nil {
+			t.Error(err)
+		}
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Push waited for the replay")
+	}
+	if err := <-replayed; err != nil {
+		t.Fatal(err)
+	}
+
+	if length, _ := queue.Len(); length != 1 {
+		t.Errorf("%d alerts left in the spool, want the one pushed during the replay", length)
+	}
+}
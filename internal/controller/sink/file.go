@@ -0,0 +1,170 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	// Directory of the files of the file sinks if the operator is
+	// not given one
+	DefaultFileSinkRoot = "/var/log/kivebpf"
+)
+
+var (
+	ErrFileOutsideRoot = errors.New("File path is outside of the root of the file sinks")
+)
+
+// Appends each KiveAlert as a line of json to a file. The files are
+// opened on the first alert and kept open until Close.
+type File struct {
+	// Directory where the files are, the paths of the sinks are
+	// relative to It or inside It. If empty, DefaultFileSinkRoot is
+	// used
+	Root string
+
+	mutex sync.Mutex
+	files map[string]*os.File
+}
+
+func (self *File) root() string {
+
+	if self.Root == "" {
+		return DefaultFileSinkRoot
+	}
+
+	return filepath.Clean(self.Root)
+}
+
+/*
+ *  The path on the operator's filesystem of the file of a sink, which
+ *  must be inside the root directory. The sinks are written by the
+ *  operator with Its privileges, so a KivePolicy must not be able to
+ *  point them anywhere else: ".." is rejected, and so are the
+ *  directories that are symlinks to the outside of the root. The
+ *  file itself is opened without following symlinks.
+ */
+func (self *File) resolvePath(path string) (string, error) {
+
+	root := self.root()
+
+	if slices.Contains(strings.Split(path, "/"), "..") {
+		return "", fmt.Errorf("resolvePath Error %s: %w", path, ErrFileOutsideRoot)
+	}
+
+	hostPath := filepath.Join(root, path)
+	if filepath.IsAbs(path) {
+		hostPath = filepath.Clean(path)
+	}
+	if !isInside(root, hostPath) || hostPath == root {
+		return "", fmt.Errorf("resolvePath Error %s: %w", path, ErrFileOutsideRoot)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("resolvePath Error Root %s: %w", root, err)
+	}
+	realDir, err := filepath.EvalSymlinks(filepath.Dir(hostPath))
+	if err != nil {
+		return "", fmt.Errorf("resolvePath Error Directory of %s: %w", path, err)
+	}
+	if !isInside(realRoot, realDir) {
+		return "", fmt.Errorf("resolvePath Error %s: %w", path, ErrFileOutsideRoot)
+	}
+
+	return filepath.Join(realDir, filepath.Base(hostPath)), nil
+}
+
+/*
+ *  Whether dir is the root of the files, or one of the two is below
+ *  the other one
+ */
+func (self *File) Overlaps(dir string) bool {
+
+	root := self.root()
+	dir = filepath.Clean(dir)
+
+	return isInside(root, dir) || isInside(dir, root)
+}
+
+/*
+ *  Whether the clean path is dir or below It
+ */
+func isInside(dir string, path string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+func (self *File) Send(ctx context.Context, kiveSink kivev2alpha1.KiveSink, alert kivev2alpha1.KiveAlert) error {
+
+	if kiveSink.Path == "" {
+		return fmt.Errorf("File Send Error: sink %s has no path", kiveSink.Name)
+	}
+
+	jsonAlert, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("File Send Error Json Marshal: %w", err)
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.files == nil {
+		self.files = map[string]*os.File{}
+	}
+
+	file, ok := self.files[kiveSink.Path]
+	if !ok {
+		path, err := self.resolvePath(kiveSink.Path)
+		if err != nil {
+			return fmt.Errorf("File Send Error sink %s: %w", kiveSink.Name, err)
+		}
+		file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY|syscall.O_NOFOLLOW, 0600)
+		if err != nil {
+			return fmt.Errorf("File Send Error Open %s: %w", kiveSink.Path, err)
+		}
+		self.files[kiveSink.Path] = file
+	}
+
+	if _, err := file.Write(append(jsonAlert, '\n')); err != nil {
+		return fmt.Errorf("File Send Error Write %s: %w", kiveSink.Path, err)
+	}
+
+	return nil
+}
+
+func (self *File) Close() error {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	for path, file := range self.files {
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("File Close Error %s: %w", path, err)
+		}
+		delete(self.files, path)
+	}
+
+	return nil
+}
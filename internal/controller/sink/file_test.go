@@ -0,0 +1,118 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+package sink
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+func TestFileSend(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "logs"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	fileSink := &File{Root: root}
+	defer fileSink.Close()
+
+	for _, path := range []string{"alerts.log", "logs/alerts.log", filepath.Join(root, "abs.log")} {
+		kiveSink := kivev2alpha1.KiveSink{Name: "file", Type: FileSinkType, Path: path}
+		if err := fileSink.Send(context.Background(), kiveSink, kivev2alpha1.KiveAlert{}); err != nil {
+			t.Errorf("Send to %s: %v", path, err)
+		}
+	}
+	for _, path := range []string{"alerts.log", "logs/alerts.log", "abs.log"} {
+		if _, err := os.Stat(filepath.Join(root, path)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestFileSendOutsideRoot(t *testing.T) {
+
+	outside := t.TempDir()
+	root := t.TempDir()
+	// A directory and a file of the root that point outside of It
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "target.log"), filepath.Join(root, "file.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	fileSink := &File{Root: root}
+	defer fileSink.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr error
+	}{
+		{"absolute", filepath.Join(outside, "alerts.log"), ErrFileOutsideRoot},
+		{"dot dot", "../" + filepath.Base(outside) + "/alerts.log", ErrFileOutsideRoot},
+		{"dot dot inside", "logs/../alerts.log", ErrFileOutsideRoot},
+		{"root", root, ErrFileOutsideRoot},
+		{"symlink directory", "link/alerts.log", ErrFileOutsideRoot},
+		{"symlink file", "file.log", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			kiveSink := kivev2alpha1.KiveSink{Name: "file", Type: FileSinkType, Path: test.path}
+			err := fileSink.Send(context.Background(), kiveSink, kivev2alpha1.KiveAlert{})
+			if err == nil {
+				t.Fatalf("Send to %s succeeded", test.path)
+			}
+			if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Errorf("Send = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d files written outside of the root", len(entries))
+	}
+}
+
+func TestFileOverlaps(t *testing.T) {
+
+	fileSink := &File{Root: "/var/log/kivebpf"}
+
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{"/var/log/kivebpf", true},
+		{"/var/log/kivebpf/dead-letter", true},
+		{"/var/log", true},
+		{"/var/log/kivebpf-dead-letter", false},
+		{"/var/lib/kivebpf/dead-letter", false},
+	}
+
+	for _, test := range tests {
+		if got := fileSink.Overlaps(test.dir); got != test.want {
+			t.Errorf("Overlaps(%s) = %v, want %v", test.dir, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	DefaultRecordTTL        = 24 * time.Hour
+	DefaultRecordMaxRecords = 1000
+	DefaultRecordGCInterval = time.Minute
+
+	recordGCPageSize = 500
+)
+
+// Stores each KiveAlert as a KiveAlertRecord in the namespace of
+// Its KivePolicy, owned by the KivePolicy so that the records are
+// deleted with It. Expired records, and the oldest records of the
+// sinks over their maxRecords, are deleted by CollectGarbage, which
+// should run only on the leader.
+type Record struct {
+	// Client used to read the KivePolicies and to write the records,
+	// the KivePolicies should be read from Its cache. If nil, the
+	// records cannot be stored
+	Client client.Client
+	// Reader used to list the records. It should not be cached, so
+	// that the nodes do not watch the records of the whole cluster.
+	// If nil, the Client is used
+	Reader client.Reader
+}
+
+func (self *Record) Send(ctx context.Context, kiveSink kivev2alpha1.KiveSink, alert kivev2alpha1.KiveAlert) error {
+
+	if self.Client == nil {
+		return fmt.Errorf("Record Send Error: no client configured")
+	}
+
+	kivePolicy := &kivev2alpha1.KivePolicy{}
+	err := self.Client.Get(ctx, client.ObjectKey{Namespace: kiveSink.Namespace, Name: alert.PolicyName}, kivePolicy)
+	if err != nil {
+		return fmt.Errorf("Record Send Error Get KivePolicy %s/%s: %w", kiveSink.Namespace, alert.PolicyName, err)
+	}
+
+	ttl := DefaultRecordTTL
+	if kiveSink.TTL != nil && kiveSink.TTL.Duration > 0 {
+		ttl = kiveSink.TTL.Duration
+	}
+	expireTime := metav1.NewTime(time.Now().Add(ttl))
+
+	record := &kivev2alpha1.KiveAlertRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: kivePolicy.Name + "-",
+			Namespace:    kivePolicy.Namespace,
+			Labels:       recordLabels(kivePolicy.Name, kiveSink.Name),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: kivev2alpha1.GroupVersion.String(),
+					Kind:       "KivePolicy",
+					Name:       kivePolicy.Name,
+					UID:        kivePolicy.UID,
+				},
+			},
+		},
+		Alert:      alert,
+		ExpireTime: &expireTime,
+	}
+	if err := self.Client.Create(ctx, record); err != nil {
+		return fmt.Errorf("Record Send Error Create KiveAlertRecord: %w", err)
+	}
+
+	return nil
+}
+
+func (self *Record) reader() client.Reader {
+
+	if self.Reader != nil {
+		return self.Reader
+	}
+
+	return self.Client
+}
+
+// The records of a record sink
+type recordGroup struct {
+	namespace string
+	policy    string
+	sink      string
+}
+
+// Reference to a record, enough to sort and delete It
+type recordRef struct {
+	name    string
+	created metav1.Time
+}
+
+/*
+ *  Periodically delete the expired records of the cluster, and the
+ *  oldest records of the sinks that hold more than their maxRecords,
+ *  until ctx is done.
+ */
+func (self *Record) CollectGarbage(ctx context.Context, interval time.Duration) {
+
+	log := log.FromContext(ctx)
+	if self.Client == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		expired, pruned, err := self.collectGarbage(ctx, time.Now())
+		if err != nil {
+			log.Error(err, "Record Error Collect garbage")
+		}
+		if expired > 0 {
+			log.Info("Deleted expired KiveAlertRecords", "count", expired)
+		}
+		if pruned > 0 {
+			log.Info("Deleted KiveAlertRecords over the maxRecords of their sinks", "count", pruned)
+		}
+	}
+}
+
+/*
+ *  Delete the records that expired before now, then the oldest
+ *  records of each sink beyond Its maxRecords. The records are
+ *  listed once, in pages of recordGCPageSize. Returns the number of
+ *  expired and of pruned records deleted.
+ */
+func (self *Record) collectGarbage(ctx context.Context, now time.Time) (int, int, error) {
+
+	maxRecords, err := self.maxRecords(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("collectGarbage Error: %w", err)
+	}
+
+	var errs []error
+	expired := 0
+	groups := map[recordGroup][]recordRef{}
+	recordList := &kivev2alpha1.KiveAlertRecordList{}
+	for {
+		err := self.reader().List(ctx, recordList, client.Limit(recordGCPageSize), client.Continue(recordList.Continue))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("collectGarbage Error List KiveAlertRecords: %w", err))
+			break
+		}
+
+		for _, record := range recordList.Items {
+			if record.ExpireTime != nil && !record.ExpireTime.After(now) {
+				err := self.Client.Delete(ctx, &record)
+				if err != nil && !apierrors.IsNotFound(err) {
+					errs = append(errs, fmt.Errorf("collectGarbage Error Delete KiveAlertRecord %s/%s: %w", record.Namespace, record.Name, err))
+					continue
+				}
+				expired++
+				continue
+			}
+
+			group := recordGroup{
+				namespace: record.Namespace,
+				policy:    record.Labels[kivev2alpha1.KiveAlertRecordPolicyLabel],
+				sink:      record.Labels[kivev2alpha1.KiveAlertRecordSinkLabel],
+			}
+			groups[group] = append(groups[group], recordRef{name: record.Name, created: record.CreationTimestamp})
+		}
+
+		if recordList.Continue == "" {
+			break
+		}
+	}
+
+	pruned := 0
+	for group, records := range groups {
+		limit, ok := maxRecords[group]
+		if !ok {
+			limit = DefaultRecordMaxRecords
+		}
+		deleted, err := self.prune(ctx, group.namespace, records, limit)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		pruned += deleted
+	}
+
+	return expired, pruned, errors.Join(errs...)
+}
+
+/*
+ *  The maxRecords of the record sinks of the KivePolicies
+ */
+func (self *Record) maxRecords(ctx context.Context) (map[recordGroup]int, error) {
+
+	kivePolicyList := &kivev2alpha1.KivePolicyList{}
+	if err := self.Client.List(ctx, kivePolicyList); err != nil {
+		return nil, fmt.Errorf("maxRecords Error List KivePolicies: %w", err)
+	}
+
+	maxRecords := map[recordGroup]int{}
+	for _, kivePolicy := range kivePolicyList.Items {
+		for _, kiveSink := range kivePolicy.Spec.Sinks {
+			if kiveSink.Type != RecordSinkType {
+				continue
+			}
+			labels := recordLabels(kivePolicy.Name, kiveSink.Name)
+			group := recordGroup{
+				namespace: kivePolicy.Namespace,
+				policy:    labels[kivev2alpha1.KiveAlertRecordPolicyLabel],
+				sink:      labels[kivev2alpha1.KiveAlertRecordSinkLabel],
+			}
+			maxRecords[group] = DefaultRecordMaxRecords
+			if kiveSink.MaxRecords > 0 {
+				maxRecords[group] = int(kiveSink.MaxRecords)
+			}
+		}
+	}
+
+	return maxRecords, nil
+}
+
+/*
+ *  Delete the oldest of records beyond maxRecords. Returns the
+ *  number of records deleted.
+ */
+func (self *Record) prune(ctx context.Context, namespace string, records []recordRef, maxRecords int) (int, error) {
+
+	if len(records) <= maxRecords {
+		return 0, nil
+	}
+
+	// The names are generated, so they only break the ties
+	slices.SortFunc(records, func(a, b recordRef) int {
+		if order := a.created.Compare(b.created.Time); order != 0 {
+			return order
+		}
+		return strings.Compare(a.name, b.name)
+	})
+
+	var errs []error
+	deleted := 0
+	for _, ref := range records[:len(records)-maxRecords] {
+		record := &kivev2alpha1.KiveAlertRecord{
+			ObjectMeta: metav1.ObjectMeta{Name: ref.name, Namespace: namespace},
+		}
+		if err := self.Client.Delete(ctx, record); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("prune Error Delete KiveAlertRecord %s/%s: %w", namespace, ref.name, err))
+			continue
+		}
+		deleted++
+	}
+
+	return deleted, errors.Join(errs...)
+}
+
+func (self *Record) Close() error {
+	return nil
+}
+
+func recordLabels(policyName string, sinkName string) map[string]string {
+
+	return map[string]string{
+		kivev2alpha1.KiveAlertRecordPolicyLabel: truncateLabelValue(policyName),
+		kivev2alpha1.KiveAlertRecordSinkLabel:   truncateLabelValue(sinkName),
+	}
+}
+
+func truncateLabelValue(value string) string {
+
+	if len(value) > validation.LabelValueMaxLength {
+		// Label values must end with an alphanumeric character
+		return strings.TrimRight(value[:validation.LabelValueMaxLength], "-_.")
+	}
+
+	return value
+}
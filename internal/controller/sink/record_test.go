@@ -0,0 +1,138 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package sink
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+func newTestRecord(name string, labels map[string]string, created time.Time, expire time.Time) *kivev2alpha1.KiveAlertRecord {
+
+	expireTime := metav1.NewTime(expire)
+	return &kivev2alpha1.KiveAlertRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "kive-test",
+			Labels:            labels,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		ExpireTime: &expireTime,
+	}
+}
+
+func newRecordClient(t *testing.T, objects ...client.Object) client.Client {
+
+	scheme := runtime.NewScheme()
+	if err := kivev2alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func recordNames(t *testing.T, reader client.Reader) []string {
+
+	recordList := &kivev2alpha1.KiveAlertRecordList{}
+	if err := reader.List(context.Background(), recordList); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, record := range recordList.Items {
+		names = append(names, record.Name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+func TestRecordCollectGarbageMaxRecords(t *testing.T) {
+
+	labels := recordLabels("policy", "sink")
+	otherLabels := recordLabels("policy", "other-sink")
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	kivePolicy := &kivev2alpha1.KivePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "kive-test"},
+		Spec: kivev2alpha1.KivePolicySpec{
+			Sinks: []kivev2alpha1.KiveSink{
+				{Name: "sink", Type: RecordSinkType, MaxRecords: 2},
+				{Name: "other-sink", Type: RecordSinkType},
+			},
+		},
+	}
+	fakeClient := newRecordClient(t, kivePolicy,
+		newTestRecord("oldest", labels, now.Add(-3*time.Minute), later),
+		newTestRecord("old", labels, now.Add(-2*time.Minute), later),
+		newTestRecord("new", labels, now.Add(-time.Minute), later),
+		newTestRecord("expired", labels, now.Add(-4*time.Minute), now.Add(-time.Minute)),
+		newTestRecord("other", otherLabels, now.Add(-time.Hour), later),
+	)
+	record := &Record{Client: fakeClient}
+
+	expired, pruned, err := record.collectGarbage(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 1 || pruned != 1 {
+		t.Errorf("deleted %d expired and %d pruned records, want 1 and 1", expired, pruned)
+	}
+
+	// The records of the other sink are not counted
+	want := []string{"new", "old", "other"}
+	if got := recordNames(t, fakeClient); !slices.Equal(got, want) {
+		t.Errorf("records %v, want %v", got, want)
+	}
+}
+
+func TestRecordCollectGarbage(t *testing.T) {
+
+	labels := recordLabels("policy", "sink")
+	now := time.Now()
+
+	fakeClient := newRecordClient(t,
+		newTestRecord("expired", labels, now.Add(-time.Hour), now.Add(-time.Minute)),
+		newTestRecord("alive", labels, now.Add(-time.Hour), now.Add(time.Hour)),
+	)
+	record := &Record{Client: fakeClient, Reader: fakeClient}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		record.CollectGarbage(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for !slices.Equal(recordNames(t, fakeClient), []string{"alive"}) {
+		select {
+		case <-deadline:
+			t.Fatalf("records %v, want only the alive one", recordNames(t, fakeClient))
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,169 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+	metrics "github.com/San7o/kivebpf/internal/controller/metrics"
+)
+
+type SinkType = string
+
+const (
+	WebhookSinkType SinkType = "webhook"
+	FileSinkType    SinkType = "file"
+	SyslogSinkType  SinkType = "syslog"
+	StdoutSinkType  SinkType = "stdout"
+	RecordSinkType  SinkType = "record"
+)
+
+// An AlertSink delivers a KiveAlert to a destination. The same
+// AlertSink is shared by all the sinks of its type, the
+// destination-specific configuration is passed with each alert.
+type AlertSink interface {
+	Send(ctx context.Context, kiveSink kivev2alpha1.KiveSink, alert kivev2alpha1.KiveAlert) error
+	Close() error
+}
+
+var (
+	AlertSinks map[SinkType]AlertSink = make(map[SinkType]AlertSink)
+)
+
+func init() {
+	AlertSinks = map[SinkType]AlertSink{
+		WebhookSinkType: &Webhook{MaxRetries: DefaultWebhookMaxRetries},
+		FileSinkType:    &File{},
+		SyslogSinkType:  &Syslog{},
+		StdoutSinkType:  &Stdout{},
+		RecordSinkType:  &Record{},
+	}
+}
+
+func IsSinkTypeSupported(sinkType SinkType) bool {
+	_, ok := AlertSinks[sinkType]
+	return ok
+}
+
+/*
+ *  Send the alert to every sink in kiveSinks. A failing sink does
+ *  not prevent the alert from reaching the others, the errors are
+ *  joined and returned.
+ */
+func Dispatch(ctx context.Context, kiveSinks []kivev2alpha1.KiveSink, alert kivev2alpha1.KiveAlert) error {
+
+	var errs []error
+	for _, kiveSink := range kiveSinks {
+
+		if !IsSinkTypeSupported(kiveSink.Type) {
+			errs = append(errs, fmt.Errorf("Dispatch Error: Sink type %s of sink %s is not supported.", kiveSink.Type, kiveSink.Name))
+			continue
+		}
+
+		start := time.Now()
+		err := AlertSinks[kiveSink.Type].Send(ctx, kiveSink, alert)
+		metrics.SinkDeliveryDuration.WithLabelValues(kiveSink.Type).Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.SinkDeliveriesTotal.WithLabelValues(kiveSink.Type, metrics.FailureResult).Inc()
+			errs = append(errs, fmt.Errorf("Dispatch Error Send to sink %s: %w", kiveSink.Name, err))
+			continue
+		}
+		metrics.SinkDeliveriesTotal.WithLabelValues(kiveSink.Type, metrics.SuccessResult).Inc()
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+ *  Resolve the sinks referenced by a trap among the ones declared
+ *  in the policy. The legacy callback field of the trap is
+ *  translated to a webhook sink. Secrets can only be referenced in
+ *  the namespace of the policy.
+ */
+func ResolveTrapSinks(kiveTrap kivev2alpha1.KiveTrap, kivePolicy kivev2alpha1.KivePolicy) ([]kivev2alpha1.KiveSink, error) {
+
+	var errs []error
+	resolved := []kivev2alpha1.KiveSink{}
+	if kiveTrap.Callback != "" {
+		callbackSink := kivev2alpha1.KiveSink{
+			Name: "callback",
+			Type: WebhookSinkType,
+			URL:  kiveTrap.Callback,
+			Auth: kiveTrap.CallbackAuth.DeepCopy(),
+		}
+		if err := resolveNamespaces(&callbackSink, kivePolicy.Namespace); err != nil {
+			errs = append(errs, err)
+		} else {
+			resolved = append(resolved, callbackSink)
+		}
+	}
+
+Name:
+	for _, name := range kiveTrap.Sinks {
+		for _, kiveSink := range kivePolicy.Spec.Sinks {
+			if kiveSink.Name == name {
+				kiveSink := *kiveSink.DeepCopy()
+				if err := resolveNamespaces(&kiveSink, kivePolicy.Namespace); err != nil {
+					errs = append(errs, err)
+				} else {
+					resolved = append(resolved, kiveSink)
+				}
+				continue Name
+			}
+		}
+		errs = append(errs, fmt.Errorf("ResolveTrapSinks Error: sink %s is not declared in the KivePolicy", name))
+	}
+
+	return resolved, errors.Join(errs...)
+}
+
+func resolveNamespaces(kiveSink *kivev2alpha1.KiveSink, namespace string) error {
+
+	if kiveSink.Type == RecordSinkType {
+		if kiveSink.Namespace == "" {
+			kiveSink.Namespace = namespace
+		}
+		if kiveSink.Namespace != namespace {
+			return fmt.Errorf("ResolveTrapSinks Error: sink %s stores records in namespace %s, only %s is allowed",
+				kiveSink.Name, kiveSink.Namespace, namespace)
+		}
+	}
+
+	if kiveSink.Auth == nil {
+		return nil
+	}
+	if kiveSink.Auth.Namespace == "" {
+		kiveSink.Auth.Namespace = namespace
+	}
+	if kiveSink.Auth.Namespace != namespace {
+		return fmt.Errorf("ResolveTrapSinks Error: sink %s references Secrets in namespace %s, only %s is allowed",
+			kiveSink.Name, kiveSink.Auth.Namespace, namespace)
+	}
+
+	return nil
+}
+
+func CloseSinks() error {
+
+	for sinkType, alertSink := range AlertSinks {
+		if err := alertSink.Close(); err != nil {
+			return fmt.Errorf("Sink CloseSinks Error %s: %w", sinkType, err)
+		}
+	}
+
+	return nil
+}
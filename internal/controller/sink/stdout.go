@@ -0,0 +1,51 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+// Prints each KiveAlert as a line of json on the standard output,
+// without the logger's prefix so that It can be parsed by log
+// collectors.
+type Stdout struct {
+	mutex sync.Mutex
+}
+
+func (self *Stdout) Send(ctx context.Context, kiveSink kivev2alpha1.KiveSink, alert kivev2alpha1.KiveAlert) error {
+
+	jsonAlert, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("Stdout Send Error Json Marshal: %w", err)
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if _, err := os.Stdout.Write(append(jsonAlert, '\n')); err != nil {
+		return fmt.Errorf("Stdout Send Error Write: %w", err)
+	}
+
+	return nil
+}
+
+func (self *Stdout) Close() error {
+	return nil
+}
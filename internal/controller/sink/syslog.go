@@ -0,0 +1,88 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"sync"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	DefaultSyslogTag = "kivebpf"
+)
+
+// Writes each KiveAlert as json to a local or remote syslog
+// daemon. One connection is kept for each destination.
+type Syslog struct {
+	mutex   sync.Mutex
+	writers map[string]*syslog.Writer
+}
+
+func (self *Syslog) Send(ctx context.Context, kiveSink kivev2alpha1.KiveSink, alert kivev2alpha1.KiveAlert) error {
+
+	jsonAlert, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("Syslog Send Error Json Marshal: %w", err)
+	}
+
+	tag := kiveSink.Tag
+	if tag == "" {
+		tag = DefaultSyslogTag
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.writers == nil {
+		self.writers = map[string]*syslog.Writer{}
+	}
+
+	key := kiveSink.Network + "://" + kiveSink.Address + "/" + tag
+	writer, ok := self.writers[key]
+	if !ok {
+		writer, err = syslog.Dial(kiveSink.Network, kiveSink.Address, syslog.LOG_WARNING|syslog.LOG_AUTH, tag)
+		if err != nil {
+			return fmt.Errorf("Syslog Send Error Dial: %w", err)
+		}
+		self.writers[key] = writer
+	}
+
+	if err := writer.Warning(string(jsonAlert)); err != nil {
+		// The connection may be stale, dial again on the next alert
+		writer.Close()
+		delete(self.writers, key)
+		return fmt.Errorf("Syslog Send Error Write: %w", err)
+	}
+
+	return nil
+}
+
+func (self *Syslog) Close() error {
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	for key, writer := range self.writers {
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("Syslog Close Error: %w", err)
+		}
+		delete(self.writers, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+)
+
+const (
+	DefaultWebhookMaxRetries     = 3
+	DefaultWebhookInitialBackoff = 500 * time.Millisecond
+	DefaultWebhookMaxBackoff     = 5 * time.Second
+	DefaultWebhookTimeout        = 10 * time.Second
+)
+
+var (
+	defaultWebhookClient = &http.Client{Timeout: DefaultWebhookTimeout}
+)
+
+// Sends the KiveAlert as json data with an HTTP POST request. A
+// request is retried MaxRetries times with an exponential backoff
+// if It fails or the endpoint does not answer with a 2xx status
+// code. If DeadLetters is set, alerts that could not be delivered
+// are spooled there and replayed by ReplayDeadLetters. Secrets
+// referenced by the sinks for signing and mTLS are read with
+// Secrets.
+type Webhook struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Client         *http.Client
+	DeadLetters    *DeadLetterQueue
+	Secrets        client.Reader
+
+	auth webhookAuth
+}
+
+func (self *Webhook) Send(ctx context.Context, kiveSink kivev2alpha1.KiveSink, alert kivev2alpha1.KiveAlert) error {
+
+	jsonAlert, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("Webhook Send Error Json Marshal: %w", err)
+	}
+
+	backoff := self.initialBackoff()
+	for attempt := 0; ; attempt++ {
+
+		err = self.post(ctx, kiveSink, jsonAlert)
+		if err == nil {
+			return nil
+		}
+		if attempt >= self.maxRetries() {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Webhook Send Error: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, self.maxBackoff())
+	}
+
+	if self.DeadLetters != nil {
+		if spoolErr := self.DeadLetters.Push(kiveSink, alert); spoolErr != nil {
+			return fmt.Errorf("Webhook Send Error Spool undelivered alert: %w (delivery: %w)", spoolErr, err)
+		}
+		return fmt.Errorf("Webhook Send Error alert spooled after %d retries: %w", self.maxRetries(), err)
+	}
+
+	return fmt.Errorf("Webhook Send Error alert dropped after %d retries: %w", self.maxRetries(), err)
+}
+
+/*
+ *  Periodically try to deliver the spooled alerts until ctx is
+ *  done. Each alert is tried once per replay, without backoff.
+ */
+func (self *Webhook) ReplayDeadLetters(ctx context.Context, interval time.Duration) {
+
+	log := log.FromContext(ctx)
+	if self.DeadLetters == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		delivered, err := self.DeadLetters.Replay(ctx, func(ctx context.Context, letter DeadLetter) error {
+			jsonAlert, err := json.Marshal(letter.Alert)
+			if err != nil {
+				return err
+			}
+			return self.post(ctx, letter.Sink, jsonAlert)
+		})
+		if err != nil {
+			log.Error(err, "Webhook Error Replay dead letters")
+		}
+		if delivered > 0 {
+			log.Info("Delivered spooled alerts", "count", delivered)
+		}
+	}
+}
+
+func (self *Webhook) Close() error {
+	return nil
+}
+
+func (self *Webhook) post(ctx context.Context, kiveSink kivev2alpha1.KiveSink, body []byte) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, kiveSink.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("New request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// The request is signed again at each attempt so that the
+	// timestamp of a retried or replayed alert is never stale
+	if err := self.auth.sign(ctx, self.Secrets, kiveSink.Auth, req, body); err != nil {
+		return fmt.Errorf("Sign: %w", err)
+	}
+
+	httpClient, err := self.auth.httpClient(ctx, self.Secrets, kiveSink.Auth, self.client())
+	if err != nil {
+		return fmt.Errorf("mTLS client: %w", err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Post: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so that the connection can be reused
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Post: endpoint %s answered with status %s", kiveSink.URL, resp.Status)
+	}
+
+	return nil
+}
+
+func (self *Webhook) client() *http.Client {
+	if self.Client == nil {
+		return defaultWebhookClient
+	}
+	return self.Client
+}
+
+func (self *Webhook) maxRetries() int {
+	if self.MaxRetries < 0 {
+		return 0
+	}
+	return self.MaxRetries
+}
+
+func (self *Webhook) initialBackoff() time.Duration {
+	if self.InitialBackoff <= 0 {
+		return DefaultWebhookInitialBackoff
+	}
+	return self.InitialBackoff
+}
+
+func (self *Webhook) maxBackoff() time.Duration {
+	if self.MaxBackoff <= 0 {
+		return DefaultWebhookMaxBackoff
+	}
+	return self.MaxBackoff
+}
@@ -0,0 +1,256 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	kivev1 "github.com/San7o/kivebpf/api/v1"
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+	container "github.com/San7o/kivebpf/internal/controller/container"
+	containertest "github.com/San7o/kivebpf/internal/controller/container/containertest"
+	ebpf "github.com/San7o/kivebpf/internal/controller/ebpf"
+	ebpftest "github.com/San7o/kivebpf/internal/controller/ebpf/ebpftest"
+	sink "github.com/San7o/kivebpf/internal/controller/sink"
+)
+
+// The reconcilers run against a local API server, with the runtime
+// and the eBPF programs replaced by in-memory fakes. The binaries of
+// the API server are found through KUBEBUILDER_ASSETS, which is set
+// by "make test-envtest".
+
+const (
+	// Namespace of the KivePolicies and the pods of the tests
+	testNamespaceName = "kive-test"
+	// Prefix of the container ids of the fake runtime
+	fakeRuntimeName = "fake"
+
+	// Maximum time for the reconcilers to react
+	timeout  = 10 * time.Second
+	interval = 100 * time.Millisecond
+)
+
+var (
+	testEnv     *envtest.Environment
+	k8sClient   client.Client
+	ctx         context.Context
+	cancel      context.CancelFunc
+	fakeTracer  *ebpftest.Tracer
+	fakeRuntime *containertest.Runtime
+)
+
+func TestControllers(t *testing.T) {
+
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set, run the tests with make test-envtest")
+	}
+
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Kive Controllers Suite")
+}
+
+var _ = BeforeSuite(func() {
+
+	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+	ctx, cancel = context.WithCancel(context.Background())
+
+	By("Starting the API server")
+	testEnv = &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := testEnv.Start()
+	Expect(err).NotTo(HaveOccurred())
+
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(kivev1.AddToScheme(scheme)).To(Succeed())
+	Expect(kivev2alpha1.AddToScheme(scheme)).To(Succeed())
+
+	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme})
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, name := range []string{kivev2alpha1.Namespace, testNamespaceName} {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+	}
+
+	KernelID = "kive-test-kernel"
+	NodeName = "kive-test-node"
+
+	fakeTracer = ebpftest.NewTracer()
+	fakeRuntime = containertest.NewRuntime()
+	sink.AlertSinks[sink.RecordSinkType] = &sink.Record{Client: k8sClient}
+
+	// One manager for each reconciler, like the operator
+	newManager := func(cacheOptions cache.Options) ctrl.Manager {
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+			Scheme:  scheme,
+			Metrics: metricsserver.Options{BindAddress: "0"},
+			Cache:   cacheOptions,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return mgr
+	}
+
+	kivePolicyMgr := newManager(NodeCacheOptions(NodeName))
+	err = (&KivePolicyReconciler{
+		Client:         kivePolicyMgr.GetClient(),
+		UncachedClient: kivePolicyMgr.GetAPIReader(),
+		Scheme:         kivePolicyMgr.GetScheme(),
+		RescanInterval: time.Second,
+		Runtimes:       map[string]container.Runtime{fakeRuntimeName: fakeRuntime},
+	}).SetupWithManager(kivePolicyMgr)
+	Expect(err).NotTo(HaveOccurred())
+
+	kiveDataMgr := newManager(cache.Options{})
+	err = (&KiveDataReconciler{
+		Client:         kiveDataMgr.GetClient(),
+		UncachedClient: kiveDataMgr.GetAPIReader(),
+		Scheme:         kiveDataMgr.GetScheme(),
+		Output: &AlertPipeline{
+			LostEventsInterval: interval,
+			// The processes of the accesses are read from the procfs
+			// of the machine running the tests
+			ProcMountpoints: []string{"/proc"},
+		},
+		Tracer: fakeTracer,
+		Index:  ebpf.NewInodeIndex(),
+	}).SetupWithManager(kiveDataMgr)
+	Expect(err).NotTo(HaveOccurred())
+
+	kivePodMgr := newManager(cache.Options{})
+	err = (&KivePodReconciler{
+		Client:         kivePodMgr.GetClient(),
+		UncachedClient: kivePodMgr.GetAPIReader(),
+	}).SetupWithManager(kivePodMgr)
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, mgr := range []ctrl.Manager{kivePolicyMgr, kiveDataMgr, kivePodMgr} {
+		go func() {
+			defer GinkgoRecover()
+			Expect(mgr.Start(ctx)).To(Succeed())
+		}()
+	}
+})
+
+var _ = AfterSuite(func() {
+
+	cancel()
+	Expect(fakeTracer.Unload(context.Background())).To(Succeed())
+	Expect(testEnv.Stop()).To(Succeed())
+})
+
+/*
+ *  Create a running pod on nodeName with a container known by the
+ *  fake runtime, whose trapped file has inode ino.
+ */
+func createTestPod(name string, nodeName string, containerID string, ino uint64) *corev1.Pod {
+
+	return createTestPodWithData(name, nodeName, containerID, container.ContainerData{
+		Ino:     ino,
+		DevID:   1,
+		IsFound: true,
+	})
+}
+
+/*
+ *  Create a running pod on nodeName with a container for which the
+ *  fake runtime returns containerData.
+ */
+func createTestPodWithData(name string, nodeName string, containerID string, containerData container.ContainerData) *corev1.Pod {
+
+	fakeRuntime.SetContainer(containerID, containerData)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespaceName,
+		},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{{
+				Name:  name,
+				Image: "nginx:latest",
+			}},
+		},
+	}
+	Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+
+	// There is no kubelet, the status is set by the test
+	pod.Status = corev1.PodStatus{
+		Phase: corev1.PodRunning,
+		PodIP: "10.0.0.1",
+		ContainerStatuses: []corev1.ContainerStatus{{
+			Name:        name,
+			ContainerID: fakeRuntimeName + "://" + containerID,
+			Image:       "nginx:latest",
+			ImageID:     "nginx@sha256:" + containerID,
+			Ready:       true,
+		}},
+	}
+	Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+	return pod
+}
+
+/*
+ *  Delete a pod without waiting for the kubelet
+ */
+func deleteTestPod(pod *corev1.Pod) {
+	Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, pod, client.GracePeriodSeconds(0)))).To(Succeed())
+}
+
+/*
+ *  The KiveData of the containers of a pod
+ */
+func podKiveData(g Gomega, podName string) []kivev2alpha1.KiveData {
+
+	kiveDataList := &kivev2alpha1.KiveDataList{}
+	g.Expect(k8sClient.List(ctx, kiveDataList, client.InNamespace(kivev2alpha1.Namespace))).To(Succeed())
+
+	kiveDatas := []kivev2alpha1.KiveData{}
+	for _, kiveData := range kiveDataList.Items {
+		if kiveData.Annotations["pod-name"] == podName {
+			kiveDatas = append(kiveDatas, kiveData)
+		}
+	}
+
+	return kiveDatas
+}
+
+/*
+ *  Key of the eBPF maps of a file trapped in a container of the fake
+ *  runtime
+ */
+func fakeMapKey(ino uint64) ebpf.BpfMapKey {
+	return ebpf.BpfMapKey{Inode: ino, Dev: 1}
+}
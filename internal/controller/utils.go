@@ -19,13 +19,18 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 
 	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
 	container "github.com/San7o/kivebpf/internal/controller/container"
+	ebpf "github.com/San7o/kivebpf/internal/controller/ebpf"
+	events "github.com/San7o/kivebpf/internal/controller/events"
+	sink "github.com/San7o/kivebpf/internal/controller/sink"
 )
 
 func KiveTrapHashID(kiveTrap kivev2alpha1.KiveTrap, alertVersion string) (string, error) {
@@ -43,7 +48,10 @@ func KiveTrapHashID(kiveTrap kivev2alpha1.KiveTrap, alertVersion string) (string
 	return shaPolicy[:63], nil
 }
 
-func NewKiveDataName(inode uint64, dev uint32, pod corev1.Pod, containerStatus corev1.ContainerStatus) string {
+// Name of the KiveData of the file inode on dev trapped by the trap
+// trapID in a container. The traps that overlap on the same file,
+// in one or more KivePolicies, have one KiveData each.
+func NewKiveDataName(inode uint64, dev uint32, pod corev1.Pod, containerStatus corev1.ContainerStatus, trapID string) string {
 
 	_, containerID, _ := container.SplitContainerRuntimeID(containerStatus.ContainerID)
 	return strconv.FormatUint(inode, 10) +
@@ -52,7 +60,9 @@ func NewKiveDataName(inode uint64, dev uint32, pod corev1.Pod, containerStatus c
 		"-kive-data-" +
 		pod.Name +
 		"-" +
-		containerID
+		containerID +
+		"-" +
+		trapID[:min(len(trapID), KiveDataNameTrapIDLength)]
 }
 
 func RegexMatch(regex string, containerName string) (bool, error) {
@@ -109,3 +119,220 @@ func KiveDataContainerCmp(kiveData kivev2alpha1.KiveData, pod corev1.Pod, contai
 
 	return true
 }
+
+// Get the sinks where the alerts generated from this KiveData
+// should be sent
+func KiveDataSinks(kiveData kivev2alpha1.KiveData) ([]kivev2alpha1.KiveSink, error) {
+
+	kiveSinks := []kivev2alpha1.KiveSink{}
+	jsonSinks := kiveData.Annotations["sinks"]
+	if jsonSinks == "" {
+		// KiveData created before sinks were introduced
+		if kiveData.Annotations["callback"] != "" {
+			kiveSinks = append(kiveSinks, kivev2alpha1.KiveSink{
+				Name: "callback",
+				Type: sink.WebhookSinkType,
+				URL:  kiveData.Annotations["callback"],
+			})
+		}
+		return kiveSinks, nil
+	}
+
+	if err := json.Unmarshal([]byte(jsonSinks), &kiveSinks); err != nil {
+		return []kivev2alpha1.KiveSink{}, fmt.Errorf("KiveDataSinks Error Json Unmarshal: %w", err)
+	}
+
+	return kiveSinks, nil
+}
+
+// References to the KivePolicy and the Pod of a KiveData, used to
+// record Events on them. The reference to the KivePolicy is nil for
+// KiveData created before Its namespace was annotated
+func KiveDataEventReferences(kiveData kivev2alpha1.KiveData) (kivePolicy *corev1.ObjectReference, pod *corev1.ObjectReference) {
+
+	if kiveData.Annotations["kive-policy-namespace"] != "" {
+		kivePolicy = events.KivePolicyReference(kiveData.Annotations["kive-policy-namespace"],
+			kiveData.Annotations["kive-policy-name"], kiveData.Annotations["kive-policy-uid"])
+	}
+	pod = events.PodReference(kiveData.Annotations["namespace"],
+		kiveData.Annotations["pod-name"], kiveData.Annotations["pod-uid"])
+
+	return kivePolicy, pod
+}
+
+// Get the filter of the traced_inodes map for the trap of this
+// KiveData
+func KiveDataAccessFilter(kiveData kivev2alpha1.KiveData) (uint8, error) {
+
+	access := []kivev2alpha1.KiveAccess{}
+	if kiveData.Annotations["access"] != "" {
+		for _, kind := range strings.Split(kiveData.Annotations["access"], ",") {
+			access = append(access, kivev2alpha1.KiveAccess(kind))
+		}
+	}
+
+	filter, err := ebpf.AccessFilter(access)
+	if err != nil {
+		return 0, fmt.Errorf("KiveDataAccessFilter Error: %w", err)
+	}
+
+	return filter, nil
+}
+
+// Get the allowlist of the trap of this KiveData, nil if It has
+// none
+func KiveDataAllow(kiveData kivev2alpha1.KiveData) (*kivev2alpha1.KiveTrapAllow, error) {
+
+	jsonAllow := kiveData.Annotations["allow"]
+	if jsonAllow == "" {
+		return nil, nil
+	}
+
+	allow := &kivev2alpha1.KiveTrapAllow{}
+	if err := json.Unmarshal([]byte(jsonAllow), allow); err != nil {
+		return nil, fmt.Errorf("KiveDataAllow Error Json Unmarshal: %w", err)
+	}
+
+	return allow, nil
+}
+
+// Get the value of the denied_inodes map for the trap of this
+// KiveData, 0 if Its accesses are not denied. If dryRun is set, the
+// accesses are never denied.
+func KiveDataDenyAction(kiveData kivev2alpha1.KiveData, dryRun bool) uint8 {
+
+	if kiveData.Annotations["action"] != string(kivev2alpha1.KiveTrapActionDeny) {
+		return 0
+	}
+	if dryRun || kiveData.Annotations["dry-run"] == "true" {
+		return ebpf.DenyDryRun
+	}
+
+	return ebpf.DenyEnforce
+}
+
+// Whether an access reported by the eBPF program matches the trap of
+// this KiveData. The eBPF program applies the filters and the
+// allowlists of all the traps of a file at once.
+func KiveDataMatches(kiveData kivev2alpha1.KiveData, data ebpf.BpfLogData) (bool, error) {
+
+	filter, err := KiveDataAccessFilter(kiveData)
+	if err != nil {
+		return false, fmt.Errorf("KiveDataMatches Error: %w", err)
+	}
+	allow, err := KiveDataAllow(kiveData)
+	if err != nil {
+		return false, fmt.Errorf("KiveDataMatches Error: %w", err)
+	}
+
+	return ebpf.EventMatches(data, filter, allow), nil
+}
+
+// Get the filter of the traced_inodes map for a file trapped by the
+// traps of kiveDatas, the union of their filters
+func KiveDataListAccessFilter(kiveDatas []kivev2alpha1.KiveData) (uint8, error) {
+
+	filter := uint8(0)
+	for _, kiveData := range kiveDatas {
+		kiveDataFilter, err := KiveDataAccessFilter(kiveData)
+		if err != nil {
+			return 0, fmt.Errorf("KiveDataListAccessFilter Error KiveData %s: %w", kiveData.Name, err)
+		}
+		filter |= kiveDataFilter
+	}
+
+	return filter, nil
+}
+
+// Get the allowlist of a file trapped by the traps of kiveDatas, the
+// processes allowed by all of them, nil if one of them has none
+func KiveDataListAllow(kiveDatas []kivev2alpha1.KiveData) (*kivev2alpha1.KiveTrapAllow, error) {
+
+	var allow *kivev2alpha1.KiveTrapAllow
+	for i, kiveData := range kiveDatas {
+		kiveDataAllow, err := KiveDataAllow(kiveData)
+		if err != nil {
+			return nil, fmt.Errorf("KiveDataListAllow Error KiveData %s: %w", kiveData.Name, err)
+		}
+		if kiveDataAllow == nil {
+			return nil, nil
+		}
+		if i == 0 {
+			allow = kiveDataAllow
+			continue
+		}
+
+		allow = &kivev2alpha1.KiveTrapAllow{
+			Binaries: intersect(allow.Binaries, kiveDataAllow.Binaries),
+			Uids:     intersect(allow.Uids, kiveDataAllow.Uids),
+			Gids:     intersect(allow.Gids, kiveDataAllow.Gids),
+			Pids:     intersect(allow.Pids, kiveDataAllow.Pids),
+		}
+	}
+
+	return allow, nil
+}
+
+// Get the value of the denied_inodes map for a file trapped by the
+// traps of kiveDatas, the strongest of their actions. An access is
+// denied if any of the traps denies It.
+func KiveDataListDenyAction(kiveDatas []kivev2alpha1.KiveData, dryRun bool) uint8 {
+
+	action := uint8(0)
+	for _, kiveData := range kiveDatas {
+		switch KiveDataDenyAction(kiveData, dryRun) {
+		case ebpf.DenyEnforce:
+			return ebpf.DenyEnforce
+		case ebpf.DenyDryRun:
+			action = ebpf.DenyDryRun
+		}
+	}
+
+	return action
+}
+
+func intersect[T comparable](a []T, b []T) []T {
+
+	out := []T{}
+	for _, item := range a {
+		if slices.Contains(b, item) && !slices.Contains(out, item) {
+			out = append(out, item)
+		}
+	}
+
+	return out
+}
+
+// Get the dedup window of the trap of this KiveData, 0 if the
+// alerts are not aggregated
+func KiveDataDedupWindow(kiveData kivev2alpha1.KiveData) (time.Duration, error) {
+
+	window := kiveData.Annotations["dedup-window"]
+	if window == "" {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(window)
+	if err != nil {
+		return 0, fmt.Errorf("KiveDataDedupWindow Error Parse duration: %w", err)
+	}
+
+	return duration, nil
+}
+
+// Get the rate limit of the trap of this KiveData, nil if the
+// alerts are not limited
+func KiveDataRateLimit(kiveData kivev2alpha1.KiveData) (*kivev2alpha1.KiveRateLimit, error) {
+
+	jsonRateLimit := kiveData.Annotations["rate-limit"]
+	if jsonRateLimit == "" {
+		return nil, nil
+	}
+
+	rateLimit := &kivev2alpha1.KiveRateLimit{}
+	if err := json.Unmarshal([]byte(jsonRateLimit), rateLimit); err != nil {
+		return nil, fmt.Errorf("KiveDataRateLimit Error Json Unmarshal: %w", err)
+	}
+
+	return rateLimit, nil
+}
@@ -0,0 +1,161 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+	container "github.com/San7o/kivebpf/internal/controller/container"
+)
+
+const (
+	// The only container known by the fake runtime
+	FakeContainerID = "da9e46ae1873ec463c9dafd08d2be762867e92b740b5c5b4534c6ad0c270d1e5"
+	// Prefix of the container ids of the fake runtime
+	FakeRuntimeName = "fake"
+)
+
+var (
+	TrapPath = "/etc/hostname"
+)
+
+// A RuntimeService that knows one running container, whose init
+// process is this program
+type FakeRuntimeService struct {
+	runtimeapi.UnimplementedRuntimeServiceServer
+}
+
+func (self *FakeRuntimeService) Version(ctx context.Context, request *runtimeapi.VersionRequest) (*runtimeapi.VersionResponse, error) {
+
+	return &runtimeapi.VersionResponse{
+		Version:           "0.1.0",
+		RuntimeName:       FakeRuntimeName,
+		RuntimeVersion:    "0.1.0",
+		RuntimeApiVersion: "v1",
+	}, nil
+}
+
+func (self *FakeRuntimeService) ContainerStatus(ctx context.Context, request *runtimeapi.ContainerStatusRequest) (*runtimeapi.ContainerStatusResponse, error) {
+
+	if request.ContainerId != FakeContainerID {
+		return nil, status.Errorf(codes.NotFound, "container %s not found", request.ContainerId)
+	}
+
+	response := &runtimeapi.ContainerStatusResponse{
+		Status: &runtimeapi.ContainerStatus{
+			Id:    FakeContainerID,
+			State: runtimeapi.ContainerState_CONTAINER_RUNNING,
+		},
+	}
+	if request.Verbose {
+		response.Info = map[string]string{
+			"info": fmt.Sprintf(`{"pid": %d, "sandboxID": "fake"}`, os.Getpid()),
+		}
+	}
+
+	return response, nil
+}
+
+/*
+ *  Serve the fake RuntimeService on a unix socket at address.
+ */
+func ServeFakeCRI(address string) (*grpc.Server, error) {
+
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, fmt.Errorf("ServeFakeCRI Error Listen: %w", err)
+	}
+
+	server := grpc.NewServer()
+	runtimeapi.RegisterRuntimeServiceServer(server, &FakeRuntimeService{})
+	go server.Serve(listener)
+
+	return server, nil
+}
+
+func main() {
+
+	if len(os.Args) > 1 {
+		TrapPath = os.Args[1]
+	}
+
+	opts := zap.Options{
+		Development: true, // Enables console encoder and disables sampling
+	}
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	ctx := context.Background()
+	log := log.FromContext(ctx)
+
+	dir, err := os.MkdirTemp("", "cri-local")
+	if err != nil {
+		log.Error(err, "Error Create temporary directory")
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	address := filepath.Join(dir, "cri.sock")
+	server, err := ServeFakeCRI(address)
+	if err != nil {
+		log.Error(err, "Error Serve fake CRI")
+		return
+	}
+	defer server.Stop()
+
+	// Outside of the operator's container, the procfs of the node is
+	// at the usual place
+	container.ProcMountpoint = "/proc"
+	err = container.ConfigureRuntime(FakeRuntimeName + "=" + container.RuntimeKindCRI + ":" + address)
+	if err != nil {
+		log.Error(err, "Error Configure runtime")
+		return
+	}
+	defer container.CloseConnections()
+
+	kiveTrap := kivev2alpha1.KiveTrap{Path: TrapPath}
+	containerStatus := corev1.ContainerStatus{
+		Name:        "fake",
+		ContainerID: FakeRuntimeName + "://" + FakeContainerID,
+		Ready:       true,
+	}
+	containerData, err := container.GetContainerData(ctx, containerStatus, kiveTrap)
+	if err != nil {
+		log.Error(err, "Error Get container data")
+		return
+	}
+	log.Info("Resolved trap", "path", TrapPath, "inode", containerData.Ino, "dev", containerData.DevID,
+		"found", containerData.IsFound)
+
+	containerStatus.ContainerID = FakeRuntimeName + "://unknown"
+	_, err = container.GetContainerData(ctx, containerStatus, kiveTrap)
+	if !errors.Is(err, container.ErrContainerNotFound) {
+		log.Error(err, "Error Unknown container was found")
+		return
+	}
+	log.Info("Unknown container not found")
+}
@@ -0,0 +1,129 @@
+/*
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 2, June 1991
+
+ Copyright (C) 1989, 1991 Free Software Foundation, Inc.,
+ 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ Everyone is permitted to copy and distribute verbatim copies
+ of this license document, but changing it is not allowed.
+*/
+
+// SPDX-License-Identifier: GPL-2.0-only
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	kivev2alpha1 "github.com/San7o/kivebpf/api/v2alpha1"
+	container "github.com/San7o/kivebpf/internal/controller/container"
+)
+
+const (
+	// The only container known by the fake CRI-O
+	FakeContainerID = "0c37512624823392d71e99a12011148db30ba7ea2a74fc7ff8bd5f85bc7b499c"
+)
+
+var (
+	TrapPath = "/etc/hostname"
+)
+
+/*
+ *  Serve the endpoints of CRI-O used by the runtime on a unix socket
+ *  at address. The fake container runs in the pid of this program,
+ *  so Its root is the root of the machine.
+ */
+func ServeFakeCrio(address string) (*http.Server, error) {
+
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, fmt.Errorf("ServeFakeCrio Error Listen: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /info", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"storage_driver": "overlay"})
+	})
+	mux.HandleFunc("GET /containers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != FakeContainerID {
+			http.Error(w, "can't find the container with id "+r.PathValue("id"), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"name": "fake", "pid": os.Getpid()})
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+
+	return server, nil
+}
+
+func main() {
+
+	if len(os.Args) > 1 {
+		TrapPath = os.Args[1]
+	}
+
+	opts := zap.Options{
+		Development: true, // Enables console encoder and disables sampling
+	}
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	ctx := context.Background()
+	log := log.FromContext(ctx)
+
+	dir, err := os.MkdirTemp("", "crio-local")
+	if err != nil {
+		log.Error(err, "Error Create temporary directory")
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	address := filepath.Join(dir, "crio.sock")
+	server, err := ServeFakeCrio(address)
+	if err != nil {
+		log.Error(err, "Error Serve fake CRI-O")
+		return
+	}
+	defer server.Close()
+
+	// Outside of the operator's container, the procfs of the node is
+	// at the usual place
+	container.ProcMountpoint = "/proc"
+	container.ContainerRuntimes["cri-o"] = &container.Crio{Address: address}
+	defer container.CloseConnections()
+
+	kiveTrap := kivev2alpha1.KiveTrap{Path: TrapPath}
+	containerStatus := corev1.ContainerStatus{
+		Name:        "fake",
+		ContainerID: "cri-o://" + FakeContainerID,
+		Ready:       true,
+	}
+	containerData, err := container.GetContainerData(ctx, containerStatus, kiveTrap)
+	if err != nil {
+		log.Error(err, "Error Get container data")
+		return
+	}
+	log.Info("Resolved trap", "path", TrapPath, "inode", containerData.Ino, "dev", containerData.DevID,
+		"found", containerData.IsFound)
+
+	containerStatus.ContainerID = "cri-o://unknown"
+	_, err = container.GetContainerData(ctx, containerStatus, kiveTrap)
+	if !errors.Is(err, container.ErrContainerNotFound) {
+		log.Error(err, "Error Unknown container was found")
+		return
+	}
+	log.Info("Unknown container not found")
+}
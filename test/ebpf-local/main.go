@@ -68,7 +68,7 @@ func main() {
 		Dev:   dev,
 	}
 
-	err = ebpf.AddInode(key)
+	err = ebpf.AddInode(key, ebpf.AccessAll)
 	if err != nil {
 		log.Error(err, "Error Update map")
 	}